@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/backtest"
+	"github.com/schlubbi/score_board/internal/groups"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/repository"
+	"github.com/schlubbi/score_board/internal/scraper"
+	"github.com/schlubbi/score_board/internal/service"
+)
+
+func main() {
+	fromDir := flag.String("from", "", "read matches from a static export directory instead of scraping")
+	outPath := flag.String("out", "", "write the full JSON report to this file")
+	minPrior := flag.Int("min-prior-games", 1, "only score matches where both teams played at least this many prior matches")
+	top := flag.Int("top", 10, "number of best parameter sets to print")
+	timeout := flag.Duration("timeout", 5*time.Minute, "scrape timeout")
+	flag.Parse()
+
+	var matches []model.MatchResult
+	var err error
+	if *fromDir != "" {
+		matches, err = loadExportedMatches(*fromDir)
+	} else {
+		matches, err = scrapeMatches(*timeout)
+	}
+	if err != nil {
+		log.Fatalf("load matches: %v", err)
+	}
+
+	opts := backtest.DefaultOptions()
+	opts.MinPriorGames = *minPrior
+	report := backtest.Run(matches, opts)
+
+	fmt.Printf("Matches: %d total, %d scored (%d draws)\n\n", report.TotalMatches, report.ScoredMatches, report.Draws)
+	fmt.Println("Best per method:")
+	for _, method := range []string{"baseline", "elo", "glicko", "power"} {
+		if res, ok := report.Best[method]; ok {
+			printScore(res)
+		}
+	}
+	fmt.Printf("\nTop %d overall:\n", *top)
+	for i, res := range report.Results {
+		if i >= *top {
+			break
+		}
+		printScore(res)
+	}
+
+	if *outPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("marshal report: %v", err)
+		}
+		if err := os.WriteFile(*outPath, data, 0o644); err != nil {
+			log.Fatalf("write %s: %v", *outPath, err)
+		}
+	}
+}
+
+func printScore(res backtest.Score) {
+	fmt.Printf("  %-8s %-28s log-loss %.4f  brier %.4f  accuracy %.3f  (n=%d)\n",
+		res.Method, res.Label, res.LogLoss, res.Brier, res.Accuracy, res.Matches)
+}
+
+func scrapeMatches(timeout time.Duration) ([]model.MatchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	leagueConfigs := make([]model.GroupConfig, 0)
+	for _, g := range groups.KasselEJugend() {
+		leagueConfigs = append(leagueConfigs, model.GroupConfig{ID: g.ID, Name: g.Name, StaffelID: g.StaffelID})
+	}
+
+	repo := repository.New()
+	s := scraper.New(nil)
+	svc := service.New(s, repo, leagueConfigs, nil, "")
+	log.Println("scraping league ...")
+	if err := svc.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	// Refresh adds the match dates required to replay the season in order.
+	return repo.AllMatches(), nil
+}
+
+// loadExportedMatches reads the matches_*.json files written by cmd/export.
+func loadExportedMatches(dir string) ([]model.MatchResult, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "matches_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no matches_*.json files in %s", dir)
+	}
+
+	seen := make(map[string]struct{})
+	matches := make([]model.MatchResult, 0)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var payload struct {
+			Matches []model.MatchResult `json:"matches"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+		for _, m := range payload.Matches {
+			if _, ok := seen[m.ID]; ok {
+				continue
+			}
+			seen[m.ID] = struct{}{}
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
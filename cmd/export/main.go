@@ -9,13 +9,21 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/schlubbi/score_board/internal/club"
+	"github.com/schlubbi/score_board/internal/geo"
 	"github.com/schlubbi/score_board/internal/groups"
+	"github.com/schlubbi/score_board/internal/highlights"
+	"github.com/schlubbi/score_board/internal/history"
 	"github.com/schlubbi/score_board/internal/model"
 	"github.com/schlubbi/score_board/internal/power"
+	"github.com/schlubbi/score_board/internal/profile"
+	"github.com/schlubbi/score_board/internal/ranking"
 	"github.com/schlubbi/score_board/internal/recommendation"
 	"github.com/schlubbi/score_board/internal/repository"
+	"github.com/schlubbi/score_board/internal/schedule"
 	"github.com/schlubbi/score_board/internal/scraper"
 	"github.com/schlubbi/score_board/internal/service"
 )
@@ -23,8 +31,74 @@ import (
 func main() {
 	outDir := flag.String("out", "web/public/data", "output directory")
 	timeout := flag.Duration("timeout", 60*time.Second, "scrape timeout")
+	marginMode := flag.String("margin", "linear", "margin dampening for overall rankings: linear, cap, log or diminishing")
+	marginCap := flag.String("margin-cap", "", "goal cap for the cap and diminishing margin modes")
+	formulaName := flag.String("formula", "", "power formula preset (default, documented, balanced, robust, percentile)")
+	weights := flag.String("weights", "", "custom power weights as offense,defense,dominance")
+	normalization := flag.String("normalization", "", "normalization: minmax, zscore, percentile or robust")
+	shrink := flag.String("shrink", "none", "shrink per-game rates towards the group or league mean: none, group or league")
+	priorGames := flag.String("prior-games", "", "shrinkage prior weight in games (empty estimates it from the data)")
+	formHalfLife := flag.String("form-half-life", "", "form half-life in days (default 28)")
+	formLast := flag.String("form-last", "", "number of games in the recent form table (default 5)")
+	homeAdvantageFlag := flag.String("home-advantage", "auto", "Elo home advantage: auto (estimated from league matches), off or rating points")
+	clubAliases := flag.String("club-aliases", "", "JSON file mapping team or club names to club names")
+	locationsFile := flag.String("locations", "", "JSON file with club or team home coordinates for the travel term of group recommendations")
+	constraintsFile := flag.String("constraints", "", "JSON file with group recommendation constraints, written to recommendations_constrained.json")
+	scheduleStart := flag.String("schedule-start", "", "first matchday of the balanced recommendation's schedule, YYYY-MM-DD (default next Saturday)")
+	scheduleBlackouts := flag.String("schedule-blackouts", "", "comma-separated dates without matches, YYYY-MM-DD")
+	scheduleDouble := flag.Bool("schedule-double", false, "schedule a double round robin")
+	stabilityResamples := flag.Int("stability-resamples", recommendation.DefaultStabilityOptions().Resamples, "bootstrap resamples for stability_<strategy>.json (0 skips them)")
 	flag.Parse()
 
+	margin, err := power.ParseMarginConfig(*marginMode, *marginCap)
+	if err != nil {
+		log.Fatalf("margin: %v", err)
+	}
+	formula, err := power.ResolveFormula(power.DefaultFormula(), *formulaName, *weights, *normalization)
+	if err != nil {
+		log.Fatalf("formula: %v", err)
+	}
+	shrinkage, err := power.ParseShrinkageConfig(*shrink, *priorGames)
+	if err != nil {
+		log.Fatalf("shrink: %v", err)
+	}
+	metricOpts := power.MetricOptions{Formula: formula, Margin: margin, Shrinkage: shrinkage}
+	formOpts, err := power.ParseFormOptions(*formHalfLife, *formLast)
+	if err != nil {
+		log.Fatalf("form: %v", err)
+	}
+	var aliases club.Aliases
+	if *clubAliases != "" {
+		if aliases, err = club.LoadAliases(*clubAliases); err != nil {
+			log.Fatalf("club aliases: %v", err)
+		}
+	}
+	var locations geo.Locations
+	if *locationsFile != "" {
+		if locations, err = geo.LoadLocations(*locationsFile); err != nil {
+			log.Fatalf("locations: %v", err)
+		}
+	}
+	var constraints *recommendation.Constraints
+	if *constraintsFile != "" {
+		c, err := recommendation.LoadConstraints(*constraintsFile)
+		if err != nil {
+			log.Fatalf("constraints: %v", err)
+		}
+		constraints = &c
+	}
+
+	scheduleOpts := schedule.DefaultOptions(time.Now())
+	if *scheduleStart != "" {
+		if scheduleOpts.Start, err = time.Parse(schedule.DateLayout, *scheduleStart); err != nil {
+			log.Fatalf("schedule start: %v", err)
+		}
+	}
+	if scheduleOpts.Blackouts, err = schedule.ParseDates(*scheduleBlackouts); err != nil {
+		log.Fatalf("schedule blackouts: %v", err)
+	}
+	scheduleOpts.Double = *scheduleDouble
+
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
@@ -47,12 +121,6 @@ func main() {
 		log.Printf("indoor scrape failed: %v", err)
 	}
 
-	// Best-effort enrichment for match ordering on the compare visuals.
-	for _, snap := range leagueRepo.Snapshots() {
-		snap.Matches = s.EnrichMatchMetadata(ctx, snap.Matches)
-		leagueRepo.Upsert(snap)
-	}
-
 	if err := os.MkdirAll(*outDir, 0o755); err != nil {
 		log.Fatalf("mkdir: %v", err)
 	}
@@ -62,7 +130,8 @@ func main() {
 
 	// Per-group detail and per-team matches.
 	for _, snap := range leagueRepo.Snapshots() {
-		mustWrite(filepath.Join(*outDir, fmt.Sprintf("group_%s.json", snap.Config.ID)), buildGroupDetail(leagueRepo, snap))
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("group_%s.json", snap.Config.ID)), buildGroupDetail(leagueRepo, snap, metricOpts, formOpts))
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("form_%s.json", snap.Config.ID)), buildGroupForm(snap, formOpts))
 		for _, team := range snap.Teams {
 			matches := filterTeamMatches(snap.Matches, team.TeamID)
 			sort.SliceStable(matches, func(i, j int) bool {
@@ -83,10 +152,71 @@ func main() {
 		}
 	}
 
-	mustWrite(filepath.Join(*outDir, "overall.json"), buildOverall(leagueRepo))
-	mustWrite(filepath.Join(*outDir, "indoor_overall.json"), buildOverall(indoorRepo))
-	mustWrite(filepath.Join(*outDir, "recommendations_simple.json"), buildSimpleRecommendation(leagueRepo, len(leagueConfigs)))
-	mustWrite(filepath.Join(*outDir, "overall_elo.json"), buildOverallElo(leagueRepo))
+	mustWrite(filepath.Join(*outDir, "overall.json"), buildOverall(leagueRepo, metricOpts, formOpts))
+	mustWrite(filepath.Join(*outDir, "indoor_overall.json"), buildOverall(indoorRepo, metricOpts, formOpts))
+	for _, strategy := range recommendation.Strategies() {
+		payload := buildRecommendation(leagueRepo, indoorRepo, leagueConfigs, metricOpts, aliases, locations, strategy, nil)
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("recommendations_%s.json", strategy)), payload)
+		if steps, ok := payload["log"].([]recommendation.DrawStep); ok {
+			writeDrawLog(filepath.Join(*outDir, "draw_log.txt"), steps)
+		}
+		groupsOut, _ := payload["groups"].([]recommendation.Group)
+		if strategy == recommendation.StrategyBalanced {
+			writeSchedule(*outDir, strategy, groupsOut, scheduleOpts)
+		}
+		if *stabilityResamples > 0 && strategy != recommendation.StrategyDraw {
+			stabilityOpts := recommendation.DefaultStabilityOptions()
+			stabilityOpts.Resamples = *stabilityResamples
+			mustWrite(filepath.Join(*outDir, fmt.Sprintf("stability_%s.json", strategy)), buildStability(leagueRepo, leagueConfigs, metricOpts, aliases, locations, strategy, groupsOut, stabilityOpts))
+		}
+	}
+	seedTeams := club.Assign(leagueRepo.AllTeams(), aliases)
+	mustWrite(filepath.Join(*outDir, "locations_seed.json"), geo.Seed(seedTeams, leagueRepo.AllMatches(), locations))
+	if constraints != nil {
+		payload := buildRecommendation(leagueRepo, indoorRepo, leagueConfigs, metricOpts, aliases, locations, recommendation.StrategyBalanced, constraints)
+		mustWrite(filepath.Join(*outDir, "recommendations_constrained.json"), payload)
+		report, _ := payload["constraints"].([]recommendation.ConstraintResult)
+		for _, result := range report {
+			if result.Status != recommendation.StatusSatisfied {
+				log.Printf("constraint %s: %s (%s)", result.Status, result.Constraint, result.Detail)
+			}
+		}
+	}
+	homeAdvantage, err := power.ResolveHomeAdvantage(*homeAdvantageFlag, leagueRepo.AllMatches())
+	if err != nil {
+		log.Fatalf("home advantage: %v", err)
+	}
+	mustWrite(filepath.Join(*outDir, "overall_elo.json"), buildOverallElo(leagueRepo, margin, homeAdvantage))
+	mustWrite(filepath.Join(*outDir, "overall_glicko.json"), buildOverallGlicko(leagueRepo))
+	mustWrite(filepath.Join(*outDir, "overall_calibrated.json"), buildOverallCalibrated(leagueRepo, indoorRepo, margin))
+	mustWrite(filepath.Join(*outDir, "home_advantage.json"), buildHomeAdvantage(leagueRepo, indoorRepo))
+	in := ratingInput(leagueRepo, indoorRepo, metricOpts, formOpts, homeAdvantage)
+	ratings := power.RateAll(in)
+	for teamID, payload := range buildTeamProfiles(leagueRepo, indoorRepo, metricOpts, formOpts, ratings) {
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("team_%s.json", teamID)), payload)
+	}
+	rankings, rankingDiffs := buildRankings(leagueRepo, metricOpts, in, ratings)
+	for method, payload := range rankings {
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("rankings_%s.json", method)), payload)
+	}
+	for pair, payload := range rankingDiffs {
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("rankings_diff_%s.json", pair)), payload)
+	}
+	powerMovements, err := ranking.Movements(in, ranking.MethodPower)
+	if err != nil {
+		log.Fatalf("movers: %v", err)
+	}
+	mustWrite(filepath.Join(*outDir, "movers_power.json"), buildMovers(leagueRepo, ranking.MethodPower, powerMovements))
+	mustWrite(filepath.Join(*outDir, "movers_table.json"), buildMovers(leagueRepo, "table", history.TableMovements(leagueRepo.AllTeams(), leagueRepo.AllMatches())))
+	mustWrite(filepath.Join(*outDir, "highlights.json"), buildHighlights(leagueRepo, in))
+	clubList, clubDetails := buildClubs(leagueRepo, indoorRepo, aliases, metricOpts, formOpts, ratings)
+	mustWrite(filepath.Join(*outDir, "clubs.json"), clubList)
+	for clubID, payload := range clubDetails {
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("club_%s.json", clubID)), payload)
+	}
+	for teamID, payload := range buildEloHistories(leagueRepo, margin, homeAdvantage) {
+		mustWrite(filepath.Join(*outDir, fmt.Sprintf("elo_history_%s.json", teamID)), payload)
+	}
 
 	log.Printf("done. wrote static json to %s", *outDir)
 }
@@ -101,7 +231,52 @@ func mustWrite(path string, payload any) {
 	}
 }
 
-func buildGroupDetail(repo *repository.Repository, snap model.GroupSnapshot) map[string]any {
+// writeSchedule writes the schedule of the groups as schedule_<strategy>.json,
+// .csv and .ics.
+func writeSchedule(outDir, strategy string, groupsOut []recommendation.Group, opts schedule.Options) {
+	plan, err := schedule.Generate(schedule.FromRecommendation(groupsOut), opts)
+	if err != nil {
+		log.Fatalf("schedule: %v", err)
+	}
+	mustWrite(filepath.Join(outDir, "schedule_"+strategy+".json"), map[string]any{"generatedAt": time.Now().UTC(), "strategy": strategy, "schedule": plan})
+	for _, file := range []struct {
+		ext   string
+		write func(*os.File) error
+	}{
+		{"csv", func(f *os.File) error { return schedule.WriteCSV(f, plan) }},
+		{"ics", func(f *os.File) error { return schedule.WriteICal(f, plan, "Schedule ("+strategy+")", time.Now()) }},
+	} {
+		path := filepath.Join(outDir, "schedule_"+strategy+"."+file.ext)
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+		if err := file.write(f); err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+	}
+	if len(plan.Clashes) > 0 {
+		log.Printf("schedule: %d club home clashes left", len(plan.Clashes))
+	}
+}
+
+// writeDrawLog writes the draw one line per ball, to be read out at the
+// assembly.
+func writeDrawLog(path string, steps []recommendation.DrawStep) {
+	var b strings.Builder
+	for _, step := range steps {
+		b.WriteString(step.String())
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		log.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func buildGroupDetail(repo *repository.Repository, snap model.GroupSnapshot, opts power.MetricOptions, formOpts power.FormOptions) map[string]any {
 	teams := make([]model.TeamStats, len(snap.Teams))
 	copy(teams, snap.Teams)
 	sort.Slice(teams, func(i, j int) bool {
@@ -117,30 +292,40 @@ func buildGroupDetail(repo *repository.Repository, snap model.GroupSnapshot) map
 		return teams[i].GoalsFor > teams[j].GoalsFor
 	})
 
-	groupMetrics := power.ComputeMetrics(teams)
-	overallMetrics := power.ComputeMetrics(repo.AllTeams())
+	groupMetrics := power.ComputeMetricsWithOptions(teams, snap.Matches, opts)
+	overallMetrics := power.ComputeMetricsWithOptions(repo.AllTeams(), repo.AllMatches(), opts)
 
 	teamPowers := make([]model.TeamPower, 0, len(teams))
 	for _, team := range teams {
 		teamPowers = append(teamPowers, model.TeamPower{Team: team, GroupMetrics: groupMetrics[team.TeamID], OverallMetrics: overallMetrics[team.TeamID]})
 	}
+	attachForm(teamPowers, power.ComputeForm(snap.Matches, formOpts))
+	attachMovements(teamPowers, history.TableMovements(snap.Teams, snap.Matches))
 
 	return map[string]any{
-		"group": model.GroupSummary{ID: snap.Config.ID, Name: snap.Config.Name, StaffelID: snap.Config.StaffelID, LastUpdated: snap.ScrapedAt, TeamCount: len(snap.Teams)},
-		"teams": teamPowers,
+		"group":   model.GroupSummary{ID: snap.Config.ID, Name: snap.Config.Name, StaffelID: snap.Config.StaffelID, LastUpdated: snap.ScrapedAt, TeamCount: len(snap.Teams)},
+		"formula": opts.Formula,
+		"teams":   teamPowers,
 	}
 }
 
-func buildOverall(repo *repository.Repository) map[string]any {
+func buildOverall(repo *repository.Repository, opts power.MetricOptions, formOpts power.FormOptions) map[string]any {
 	teams := repo.AllTeams()
-	overallMetrics := power.ComputeMetrics(teams)
-	groupMetricMap := buildGroupMetricMap(repo.Snapshots())
+	overallMetrics := power.ComputeMetricsWithOptions(teams, repo.AllMatches(), opts)
+	groupMetricMap := buildGroupMetricMap(repo.Snapshots(), opts)
 
 	teamPowers := make([]model.TeamPower, 0, len(teams))
+	lowSample := make([]string, 0)
 	for _, team := range teams {
 		gMetrics := groupMetricMap[team.GroupID][team.TeamID]
 		teamPowers = append(teamPowers, model.TeamPower{Team: team, GroupMetrics: gMetrics, OverallMetrics: overallMetrics[team.TeamID]})
+		if overallMetrics[team.TeamID].LowSample {
+			lowSample = append(lowSample, team.TeamID)
+		}
 	}
+	sort.Strings(lowSample)
+	attachForm(teamPowers, power.ComputeForm(repo.AllMatches(), formOpts))
+	attachMovements(teamPowers, history.PowerMovements(teams, repo.AllMatches(), opts))
 
 	sort.Slice(teamPowers, func(i, j int) bool {
 		pi := teamPowers[i].OverallMetrics.PowerScore
@@ -157,63 +342,121 @@ func buildOverall(repo *repository.Repository) map[string]any {
 		return teamPowers[i].Team.GoalsFor > teamPowers[j].Team.GoalsFor
 	})
 
-	return map[string]any{"updatedAt": repo.LastUpdated(), "teams": teamPowers}
+	return map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"formula":   opts.Formula,
+		"margin":    opts.Margin,
+		"shrinkage": opts.Shrinkage,
+		"lowSample": map[string]any{"minGames": power.LowSampleGames, "teamIds": lowSample},
+		"teams":     teamPowers,
+	}
 }
 
-func buildSimpleRecommendation(repo *repository.Repository, groupCount int) map[string]any {
-	teams := repo.AllTeams()
+func buildRecommendation(repo, indoorRepo *repository.Repository, groupConfigs []model.GroupConfig, opts power.MetricOptions, aliases club.Aliases, locations geo.Locations, strategy string, constraints *recommendation.Constraints) map[string]any {
+	groupCount := len(groupConfigs)
+	teams := club.Assign(repo.AllTeams(), aliases)
 	if len(teams) == 0 {
-		return map[string]any{"generatedAt": time.Now().UTC(), "totalTeams": 0, "groupCount": groupCount, "groups": []any{}}
+		return map[string]any{"generatedAt": time.Now().UTC(), "strategy": strategy, "totalTeams": 0, "groupCount": groupCount, "groups": []any{}}
 	}
 
-	overallMetrics := power.ComputeMetrics(teams)
-	groupMetricMap := buildGroupMetricMap(repo.Snapshots())
+	teamPowers := recommendation.RankTeams(teams, repo.AllMatches(), repo.Snapshots(), opts)
 
-	teamPowers := make([]model.TeamPower, 0, len(teams))
-	for _, team := range teams {
-		gMetrics := groupMetricMap[team.GroupID][team.TeamID]
-		teamPowers = append(teamPowers, model.TeamPower{Team: team, GroupMetrics: gMetrics, OverallMetrics: overallMetrics[team.TeamID]})
+	if groupCount <= 0 {
+		groupCount = 1
 	}
 
-	sort.Slice(teamPowers, func(i, j int) bool {
-		pi := teamPowers[i].OverallMetrics.PowerScore
-		pj := teamPowers[j].OverallMetrics.PowerScore
-		if pi != pj {
-			return pi > pj
+	out := map[string]any{"generatedAt": time.Now().UTC(), "strategy": strategy, "totalTeams": len(teamPowers), "groupCount": groupCount, "formula": opts.Formula}
+	targets := recommendation.TargetSizes(len(teamPowers), groupCount)
+	simple := recommendation.SimpleBalancedGroups(teamPowers, groupCount)
+	balancedOpts := recommendation.DefaultBalancedOptions()
+	balancedOpts.Locations = locations.Locate(teams)
+	switch strategy {
+	case recommendation.StrategyTiered:
+		groupsOut, tiers := recommendation.TieredGroups(teamPowers, recommendation.DefaultTieredOptions(groupCount), balancedOpts)
+		out["groupCount"] = len(groupsOut)
+		out["groups"] = groupsOut
+		out["tiers"] = tiers
+		out["seed"] = balancedOpts.Seed
+		out["iterations"] = balancedOpts.Iterations
+	case recommendation.StrategyMinimal:
+		result := recommendation.MinimalChange(teamPowers, groupConfigs, recommendation.DefaultMinimalOptions(recommendation.GoalBalance))
+		out["groupCount"] = len(result.Groups)
+		out["groups"] = result.Groups
+		out["objective"] = result.Objective
+		out["minimal"] = result
+	case recommendation.StrategyDraw:
+		drawOpts := recommendation.DefaultDrawOptions()
+		draw := recommendation.Draw(teamPowers, groupCount, drawOpts.Seed)
+		out["groups"] = draw.Groups
+		out["objective"] = draw.Objective
+		out["seed"] = draw.Seed
+		out["pots"] = draw.Pots
+		out["log"] = draw.Log
+		out["simulation"] = recommendation.SimulateDraws(teamPowers, groupCount, drawOpts)
+	case recommendation.StrategyBalanced:
+		var c recommendation.Constraints
+		if constraints != nil {
+			c = *constraints
 		}
-		if teamPowers[i].Team.GoalDiff != teamPowers[j].Team.GoalDiff {
-			return teamPowers[i].Team.GoalDiff > teamPowers[j].Team.GoalDiff
+		groupsOut, objective, report := recommendation.ConstrainedGroups(teamPowers, groupCount, c, balancedOpts)
+		if constraints != nil {
+			out["constraints"] = report
 		}
-		if teamPowers[i].Team.Points != teamPowers[j].Team.Points {
-			return teamPowers[i].Team.Points > teamPowers[j].Team.Points
-		}
-		return teamPowers[i].Team.TeamName < teamPowers[j].Team.TeamName
-	})
+		out["groups"] = groupsOut
+		out["objective"] = objective
+		out["seed"] = balancedOpts.Seed
+		out["iterations"] = balancedOpts.Iterations
+		out["baseline"] = recommendation.Measure(simple, targets, balancedOpts.Locations)
+	default:
+		out["groups"] = simple
+		out["objective"] = recommendation.Measure(simple, targets, balancedOpts.Locations)
+	}
+	groupsOut, _ := out["groups"].([]recommendation.Group)
+	out["quality"] = recommendation.Evaluate(groupsOut, recommendation.PartitionModel(repo.Snapshots(), indoorRepo.Snapshots(), opts), recommendation.DefaultQualityOptions())
+	return out
+}
 
-	if groupCount <= 0 {
-		groupCount = 1
+// buildStability reruns the strategy on bootstrap resamples of the matches,
+// with tables replayed from each resample, and reports how stable groupsOut is.
+func buildStability(repo *repository.Repository, groupConfigs []model.GroupConfig, opts power.MetricOptions, aliases club.Aliases, locations geo.Locations, strategy string, groupsOut []recommendation.Group, stabilityOpts recommendation.StabilityOptions) map[string]any {
+	teams := club.Assign(repo.AllTeams(), aliases)
+	groupCount := max(len(groupConfigs), 1)
+	strategyOpts := recommendation.StrategyOptions{
+		Balanced: recommendation.DefaultBalancedOptions(),
+		Tiered:   recommendation.DefaultTieredOptions(groupCount),
+		Minimal:  recommendation.DefaultMinimalOptions(recommendation.GoalBalance),
 	}
+	strategyOpts.Balanced.Locations = locations.Locate(teams)
+	regroup := recommendation.NewRegroup(strategy, teams, repo.Snapshots(), groupConfigs, opts, strategyOpts)
 
-	groupsOut := recommendation.SimpleBalancedGroups(teamPowers, groupCount)
-	return map[string]any{"generatedAt": time.Now().UTC(), "totalTeams": len(teamPowers), "groupCount": groupCount, "groups": groupsOut}
+	return map[string]any{
+		"generatedAt": time.Now().UTC(),
+		"strategy":    strategy,
+		"groupCount":  len(groupsOut),
+		"formula":     opts.Formula,
+		"groups":      groupsOut,
+		"stability":   recommendation.Stability(groupsOut, repo.AllMatches(), regroup, stabilityOpts),
+	}
 }
 
-func buildOverallElo(repo *repository.Repository) map[string]any {
-	snaps := repo.Snapshots()
+// leagueElo returns all matches in the order they were played together with
+// the Elo parameters, so the overall Elo ranking and the team Elo histories
+// replay the same matches the same way.
+func leagueElo(repo *repository.Repository, margin power.MarginConfig, homeAdvantage float64) ([]model.MatchResult, power.EloParams) {
 	allMatches := make([]model.MatchResult, 0)
-	for _, snap := range snaps {
+	for _, snap := range repo.Snapshots() {
 		allMatches = append(allMatches, snap.Matches...)
 	}
-	sort.SliceStable(allMatches, func(i, j int) bool {
-		if allMatches[i].MatchDate != allMatches[j].MatchDate {
-			return allMatches[i].MatchDate < allMatches[j].MatchDate
-		}
-		if allMatches[i].MatchdayTag != allMatches[j].MatchdayTag {
-			return allMatches[i].MatchdayTag < allMatches[j].MatchdayTag
-		}
-		return allMatches[i].ID < allMatches[j].ID
-	})
-	elo := power.ComputeElo(allMatches, 1500, 20)
+	model.SortChronologically(allMatches)
+	params := power.DefaultEloParams()
+	params.Margin = margin
+	params.HomeAdvantage = homeAdvantage
+	return allMatches, params
+}
+
+func buildOverallElo(repo *repository.Repository, margin power.MarginConfig, homeAdvantage float64) map[string]any {
+	allMatches, params := leagueElo(repo, margin, homeAdvantage)
+	elo, _ := power.ComputeEloWithParams(allMatches, params)
 
 	type teamElo struct {
 		Team  model.TeamStats `json:"team"`
@@ -244,13 +487,286 @@ func buildOverallElo(repo *repository.Repository) map[string]any {
 		return entries[i].Team.TeamName < entries[j].Team.TeamName
 	})
 
-	return map[string]any{"updatedAt": repo.LastUpdated(), "teams": entries}
+	return map[string]any{"updatedAt": repo.LastUpdated(), "margin": margin, "homeAdvantage": homeAdvantage, "teams": entries}
+}
+
+func buildOverallGlicko(repo *repository.Repository) map[string]any {
+	allMatches := make([]model.MatchResult, 0)
+	for _, snap := range repo.Snapshots() {
+		allMatches = append(allMatches, snap.Matches...)
+	}
+	model.SortChronologically(allMatches)
+
+	cfg := power.DefaultGlickoConfig()
+	glicko := power.ComputeGlicko(allMatches, cfg)
+
+	type teamGlicko struct {
+		Team       model.TeamStats `json:"team"`
+		Rating     float64         `json:"rating"`
+		Deviation  float64         `json:"deviation"`
+		Volatility float64         `json:"volatility"`
+		Low        float64         `json:"low"`
+		High       float64         `json:"high"`
+		Games      int             `json:"games"`
+	}
+
+	teams := repo.AllTeams()
+	entries := make([]teamGlicko, 0, len(teams))
+	for _, team := range teams {
+		res, ok := glicko[team.TeamID]
+		if !ok {
+			res = power.GlickoResult{Rating: cfg.InitialRating, Deviation: cfg.InitialDeviation, Volatility: cfg.InitialVolatility}
+		}
+		entries = append(entries, teamGlicko{
+			Team:       team,
+			Rating:     res.Rating,
+			Deviation:  res.Deviation,
+			Volatility: res.Volatility,
+			Low:        res.Rating - 2*res.Deviation,
+			High:       res.Rating + 2*res.Deviation,
+			Games:      res.Games,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		if entries[i].Deviation != entries[j].Deviation {
+			return entries[i].Deviation < entries[j].Deviation
+		}
+		if entries[i].Team.GoalDiff != entries[j].Team.GoalDiff {
+			return entries[i].Team.GoalDiff > entries[j].Team.GoalDiff
+		}
+		return entries[i].Team.TeamName < entries[j].Team.TeamName
+	})
+
+	return map[string]any{"updatedAt": repo.LastUpdated(), "period": cfg.Period, "teams": entries}
+}
+
+func buildOverallCalibrated(leagueRepo, indoorRepo *repository.Repository, margin power.MarginConfig) map[string]any {
+	in := power.CalibrationInput{
+		LeagueTeams:   leagueRepo.AllTeams(),
+		LeagueMatches: leagueRepo.AllMatches(),
+		IndoorTeams:   indoorRepo.AllTeams(),
+		IndoorMatches: indoorRepo.AllMatches(),
+		Model:         power.DefaultGoalModelOptions(),
+	}
+	in.Model.Margin = margin
+
+	result := power.Calibrate(in)
+	return map[string]any{
+		"updatedAt": leagueRepo.LastUpdated(),
+		"margin":    margin,
+		"teams":     result.Teams,
+		"groups":    result.Groups,
+		"report":    result.Report,
+	}
+}
+
+func buildEloHistories(repo *repository.Repository, margin power.MarginConfig, homeAdvantage float64) map[string]map[string]any {
+	allMatches, params := leagueElo(repo, margin, homeAdvantage)
+	elo, history := power.ComputeEloWithParams(allMatches, params)
+
+	out := make(map[string]map[string]any)
+	for _, team := range repo.AllTeams() {
+		res := elo[team.TeamID]
+		if res.Rating == 0 {
+			res.Rating = 1500
+		}
+		entries := history[team.TeamID]
+		if entries == nil {
+			entries = []power.EloHistoryEntry{}
+		}
+		payload := map[string]any{"team": team, "elo": res.Rating, "games": res.Games, "margin": margin, "homeAdvantage": homeAdvantage, "history": entries}
+		if move, ok := power.BiggestEloMove(entries); ok {
+			payload["biggestMove"] = move
+		}
+		out[team.TeamID] = payload
+	}
+	return out
+}
+
+func buildHomeAdvantage(leagueRepo, indoorRepo *repository.Repository) map[string]any {
+	type groupAdvantage struct {
+		GroupID       string              `json:"groupId"`
+		GroupName     string              `json:"groupName"`
+		HomeAdvantage power.HomeAdvantage `json:"homeAdvantage"`
+	}
+	groupsOut := make([]groupAdvantage, 0)
+	for _, snap := range leagueRepo.Snapshots() {
+		groupsOut = append(groupsOut, groupAdvantage{
+			GroupID:       snap.Config.ID,
+			GroupName:     snap.Config.Name,
+			HomeAdvantage: power.EstimateHomeAdvantage(snap.Matches),
+		})
+	}
+
+	competitions := map[string]any{
+		"league": power.EstimateHomeAdvantage(leagueRepo.AllMatches()),
+		"indoor": power.EstimateHomeAdvantage(indoorRepo.AllMatches()),
+	}
+	return map[string]any{"updatedAt": leagueRepo.LastUpdated(), "competitions": competitions, "groups": groupsOut}
+}
+
+func ratingInput(leagueRepo, indoorRepo *repository.Repository, opts power.MetricOptions, formOpts power.FormOptions, homeAdvantage float64) power.RatingInput {
+	in := power.RatingInput{
+		LeagueTeams:   leagueRepo.AllTeams(),
+		LeagueMatches: leagueRepo.AllMatches(),
+		IndoorTeams:   indoorRepo.AllTeams(),
+		IndoorMatches: indoorRepo.AllMatches(),
+		Metrics:       opts,
+		Elo:           power.DefaultEloParams(),
+		Glicko:        power.DefaultGlickoConfig(),
+		Model:         power.DefaultGoalModelOptions(),
+		Form:          formOpts,
+	}
+	in.Elo.Margin = opts.Margin
+	in.Elo.HomeAdvantage = homeAdvantage
+	in.Model.Margin = opts.Margin
+	return in
+}
+
+func buildTeamProfiles(leagueRepo, indoorRepo *repository.Repository, opts power.MetricOptions, formOpts power.FormOptions, ratings map[string]power.TeamRatings) map[string]map[string]any {
+	profileIn := profile.Input{
+		League:  leagueRepo.Snapshots(),
+		Indoor:  indoorRepo.Snapshots(),
+		Ratings: ratings,
+		History: history.Build(leagueRepo.AllTeams(), leagueRepo.AllMatches(), opts),
+		Form:    formOpts,
+	}
+
+	out := make(map[string]map[string]any)
+	for _, team := range append(leagueRepo.AllTeams(), indoorRepo.AllTeams()...) {
+		if _, ok := out[team.TeamID]; ok {
+			continue
+		}
+		if p, ok := profile.Build(profileIn, team.TeamID); ok {
+			out[team.TeamID] = map[string]any{"updatedAt": leagueRepo.LastUpdated(), "formula": opts.Formula, "profile": p}
+		}
+	}
+	return out
+}
+
+func buildRankings(repo *repository.Repository, opts power.MetricOptions, in power.RatingInput, ratings map[string]power.TeamRatings) (map[string]map[string]any, map[string]map[string]any) {
+	teams := repo.AllTeams()
+	byMethod := make(map[string]ranking.Ranking)
+	rankings := make(map[string]map[string]any)
+	for _, method := range ranking.Methods() {
+		result, err := ranking.Build(teams, ratings, method)
+		if err != nil {
+			log.Fatalf("ranking %s: %v", method, err)
+		}
+		movements, err := ranking.Movements(in, method)
+		if err != nil {
+			log.Fatalf("ranking movements %s: %v", method, err)
+		}
+		ranking.AttachMovements(result, movements)
+		byMethod[method] = result
+		rankings[method] = map[string]any{"updatedAt": repo.LastUpdated(), "formula": opts.Formula, "methods": ranking.Methods(), "ranking": result}
+	}
+
+	diffs := make(map[string]map[string]any)
+	methods := ranking.Methods()
+	for i, a := range methods {
+		for _, b := range methods[i+1:] {
+			diffs[a+"_"+b] = map[string]any{"updatedAt": repo.LastUpdated(), "formula": opts.Formula, "diff": ranking.Compare(byMethod[a], byMethod[b])}
+		}
+	}
+	return rankings, diffs
+}
+
+func buildMovers(repo *repository.Repository, method string, movements map[string]model.Movement) map[string]any {
+	rises, falls := history.Movers(repo.AllTeams(), movements, 10)
+	return map[string]any{"updatedAt": repo.LastUpdated(), "method": method, "rises": rises, "falls": falls}
+}
+
+func buildHighlights(repo *repository.Repository, in power.RatingInput) map[string]any {
+	opts := highlights.DefaultOptions()
+	opts.Elo = in.Elo
+	opts.Model = in.Model
+	feed := highlights.Build(in.LeagueMatches, opts)
+	out := map[string]any{
+		"updatedAt":     repo.LastUpdated(),
+		"prior":         feed.Prior,
+		"homeAdvantage": in.Elo.HomeAdvantage,
+		"highlights":    feed.Highlights,
+	}
+	if len(feed.Weeks) > 0 {
+		out["weeks"] = feed.Weeks
+	}
+	return out
+}
+
+func buildClubs(leagueRepo, indoorRepo *repository.Repository, aliases club.Aliases, opts power.MetricOptions, formOpts power.FormOptions, ratings map[string]power.TeamRatings) (map[string]any, map[string]map[string]any) {
+	in := club.Input{
+		League:  leagueRepo.Snapshots(),
+		Indoor:  indoorRepo.Snapshots(),
+		Aliases: aliases,
+		Ratings: ratings,
+		Form:    formOpts,
+	}
+	clubs := club.List(in)
+	details := make(map[string]map[string]any, len(clubs))
+	for _, overview := range clubs {
+		if dashboard, ok := club.Build(in, overview.Club.ID); ok {
+			details[overview.Club.ID] = map[string]any{"updatedAt": leagueRepo.LastUpdated(), "formula": opts.Formula, "club": dashboard}
+		}
+	}
+	return map[string]any{"updatedAt": leagueRepo.LastUpdated(), "clubs": clubs}, details
+}
+
+func buildGroupForm(snap model.GroupSnapshot, formOpts power.FormOptions) map[string]any {
+	forms := power.ComputeForm(snap.Matches, formOpts)
+
+	type teamForm struct {
+		Team model.TeamStats `json:"team"`
+		Form model.Form      `json:"form"`
+	}
+	entries := make([]teamForm, 0, len(snap.Teams))
+	for _, team := range snap.Teams {
+		entries = append(entries, teamForm{Team: team, Form: forms[team.TeamID]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Form.Points != entries[j].Form.Points {
+			return entries[i].Form.Points > entries[j].Form.Points
+		}
+		if entries[i].Form.GoalDiff != entries[j].Form.GoalDiff {
+			return entries[i].Form.GoalDiff > entries[j].Form.GoalDiff
+		}
+		return entries[i].Team.TeamName < entries[j].Team.TeamName
+	})
+
+	return map[string]any{
+		"group":        map[string]string{"id": snap.Config.ID, "name": snap.Config.Name},
+		"halfLifeDays": formOpts.HalfLife,
+		"decayed":      power.FormDecays(snap.Matches),
+		"lastGames":    formOpts.LastN,
+		"teams":        entries,
+	}
+}
+
+// attachForm sets the form of every team that has one.
+func attachForm(teamPowers []model.TeamPower, forms map[string]model.Form) {
+	for i := range teamPowers {
+		if form, ok := forms[teamPowers[i].Team.TeamID]; ok {
+			teamPowers[i].Form = &form
+		}
+	}
+}
+
+func attachMovements(teamPowers []model.TeamPower, movements map[string]model.Movement) {
+	for i := range teamPowers {
+		if movement, ok := movements[teamPowers[i].Team.TeamID]; ok {
+			teamPowers[i].Movement = &movement
+		}
+	}
 }
 
-func buildGroupMetricMap(snaps []model.GroupSnapshot) map[string]map[string]model.MetricSet {
+func buildGroupMetricMap(snaps []model.GroupSnapshot, opts power.MetricOptions) map[string]map[string]model.MetricSet {
 	groupMetricMap := make(map[string]map[string]model.MetricSet)
 	for _, snap := range snaps {
-		groupMetricMap[snap.Config.ID] = power.ComputeMetrics(snap.Teams)
+		groupMetricMap[snap.Config.ID] = power.ComputeMetricsWithOptions(snap.Teams, snap.Matches, opts)
 	}
 	return groupMetricMap
 }
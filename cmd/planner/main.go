@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/club"
+	"github.com/schlubbi/score_board/internal/groups"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+	"github.com/schlubbi/score_board/internal/repository"
+	"github.com/schlubbi/score_board/internal/scraper"
+	"github.com/schlubbi/score_board/internal/service"
+	"github.com/schlubbi/score_board/internal/tournament"
+)
+
+const startLayout = "2006-01-02T15:04"
+
+func main() {
+	fromDir := flag.String("from", "", "read indoor ratings from a static export directory (indoor_overall.json) instead of scraping")
+	outDir := flag.String("out", ".", "output directory for tournament.json and tournament.txt")
+	teamList := flag.String("teams", "", "comma-separated team names or IDs; teams without indoor data join with a rating of 0")
+	count := flag.Int("n", 8, "number of strongest indoor teams to invite when -teams is empty")
+	title := flag.String("title", "Hallenturnier", "title of the printable schedule")
+	start := flag.String("start", "", "first kickoff, YYYY-MM-DDTHH:MM (default next Saturday 09:00)")
+	opts := tournament.DefaultOptions()
+	flag.IntVar(&opts.Groups, "groups", opts.Groups, "number of groups")
+	flag.IntVar(&opts.Pitches, "pitches", opts.Pitches, "number of pitches")
+	flag.IntVar(&opts.GameMinutes, "game", opts.GameMinutes, "game length in minutes")
+	flag.IntVar(&opts.BreakMinutes, "break", opts.BreakMinutes, "changeover between slots in minutes")
+	flag.IntVar(&opts.Slots, "slots", opts.Slots, "number of time slots available (0 for unlimited)")
+	flag.IntVar(&opts.Advance, "advance", opts.Advance, "teams per group in the knockout bracket")
+	bracket := flag.String("bracket", opts.Bracket, "bracket after the group stage: knockout or placement")
+	clubAliases := flag.String("club-aliases", "", "JSON file mapping team or club names to club names")
+	timeout := flag.Duration("timeout", 60*time.Second, "scrape timeout")
+	flag.Parse()
+
+	var err error
+	if opts.Bracket, err = tournament.ParseBracket(*bracket); err != nil {
+		log.Fatalf("bracket: %v", err)
+	}
+	if *start != "" {
+		if opts.Start, err = time.ParseInLocation(startLayout, *start, time.Local); err != nil {
+			log.Fatalf("start: want YYYY-MM-DDTHH:MM: %v", err)
+		}
+	} else {
+		opts.Start = nextSaturday(time.Now())
+	}
+	var aliases club.Aliases
+	if *clubAliases != "" {
+		if aliases, err = club.LoadAliases(*clubAliases); err != nil {
+			log.Fatalf("club aliases: %v", err)
+		}
+	}
+
+	var rated []model.TeamPower
+	if *fromDir != "" {
+		rated, err = loadIndoorRatings(*fromDir)
+	} else {
+		rated, err = scrapeIndoorRatings(*timeout)
+	}
+	if err != nil {
+		log.Fatalf("load indoor ratings: %v", err)
+	}
+	teams := selectTeams(rated, *teamList, *count)
+	stats := make([]model.TeamStats, len(teams))
+	for i, team := range teams {
+		stats[i] = team.Team
+	}
+	for i, team := range club.Assign(stats, aliases) {
+		teams[i].Team = team
+	}
+
+	plan, err := tournament.New(teams, opts)
+	if err != nil {
+		log.Fatalf("plan: %v", err)
+	}
+	for _, warning := range plan.Warnings {
+		log.Printf("warning: %s", warning)
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("mkdir: %v", err)
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal plan: %v", err)
+	}
+	writeFile(filepath.Join(*outDir, "tournament.json"), data)
+	var text bytes.Buffer
+	if err := tournament.WriteText(&text, plan, *title); err != nil {
+		log.Fatalf("print plan: %v", err)
+	}
+	writeFile(filepath.Join(*outDir, "tournament.txt"), text.Bytes())
+	fmt.Print(text.String())
+}
+
+func writeFile(path string, data []byte) {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// selectTeams picks the named teams, or the count strongest, strongest
+// first. Names match team names or IDs case-insensitively.
+func selectTeams(rated []model.TeamPower, list string, count int) []model.TeamPower {
+	sort.SliceStable(rated, func(i, j int) bool {
+		return rated[i].OverallMetrics.PowerScore > rated[j].OverallMetrics.PowerScore
+	})
+	if strings.TrimSpace(list) == "" {
+		return rated[:min(count, len(rated))]
+	}
+
+	byKey := make(map[string]model.TeamPower, 2*len(rated))
+	for _, team := range rated {
+		byKey[strings.ToLower(team.Team.TeamID)] = team
+		if _, ok := byKey[strings.ToLower(team.Team.TeamName)]; !ok {
+			byKey[strings.ToLower(team.Team.TeamName)] = team
+		}
+	}
+	var teams []model.TeamPower
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		team, ok := byKey[strings.ToLower(name)]
+		if !ok {
+			log.Printf("no indoor data for %q, rating it 0", name)
+			team = model.TeamPower{Team: model.TeamStats{TeamID: name, TeamName: name}}
+		}
+		teams = append(teams, team)
+	}
+	sort.SliceStable(teams, func(i, j int) bool {
+		return teams[i].OverallMetrics.PowerScore > teams[j].OverallMetrics.PowerScore
+	})
+	return teams
+}
+
+// loadIndoorRatings reads indoor_overall.json written by cmd/export.
+func loadIndoorRatings(dir string) ([]model.TeamPower, error) {
+	path := filepath.Join(dir, "indoor_overall.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Teams []model.TeamPower `json:"teams"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return payload.Teams, nil
+}
+
+func scrapeIndoorRatings(timeout time.Duration) ([]model.TeamPower, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	indoorRepo := repository.New()
+	svc := service.New(scraper.New(nil), repository.New(), nil, indoorRepo, groups.IndoorPreGamesStaffelID)
+	log.Println("scraping indoor ...")
+	if err := svc.RefreshIndoor(ctx); err != nil {
+		return nil, err
+	}
+
+	teams := indoorRepo.AllTeams()
+	metrics := power.ComputeMetricsWithOptions(teams, indoorRepo.AllMatches(), power.DefaultMetricOptions())
+	rated := make([]model.TeamPower, 0, len(teams))
+	for _, team := range teams {
+		rated = append(rated, model.TeamPower{Team: team, OverallMetrics: metrics[team.TeamID]})
+	}
+	return rated, nil
+}
+
+func nextSaturday(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	for day.Weekday() != time.Saturday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
@@ -13,8 +13,11 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/schlubbi/score_board/internal/api"
+	"github.com/schlubbi/score_board/internal/club"
+	"github.com/schlubbi/score_board/internal/geo"
 	"github.com/schlubbi/score_board/internal/groups"
 	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
 	"github.com/schlubbi/score_board/internal/repository"
 	"github.com/schlubbi/score_board/internal/scraper"
 	"github.com/schlubbi/score_board/internal/service"
@@ -46,7 +49,29 @@ func main() {
 		log.Printf("indoor scrape failed: %v", err)
 	}
 
-	handler := api.NewHandler(svc)
+	formula, err := power.ResolveFormula(power.DefaultFormula(), os.Getenv("POWER_FORMULA"), os.Getenv("POWER_WEIGHTS"), os.Getenv("POWER_NORMALIZATION"))
+	if err != nil {
+		log.Fatalf("power formula config: %v", err)
+	}
+	log.Printf("default power formula: %s", formula)
+
+	var aliases club.Aliases
+	if path := os.Getenv("CLUB_ALIASES"); path != "" {
+		if aliases, err = club.LoadAliases(path); err != nil {
+			log.Fatalf("club aliases: %v", err)
+		}
+		log.Printf("loaded %d club aliases", len(aliases))
+	}
+
+	var locations geo.Locations
+	if path := os.Getenv("CLUB_LOCATIONS"); path != "" {
+		if locations, err = geo.LoadLocations(path); err != nil {
+			log.Fatalf("club locations: %v", err)
+		}
+		log.Printf("loaded %d club locations", len(locations))
+	}
+
+	handler := api.NewHandler(svc, api.Options{Formula: formula, ClubAliases: aliases, Locations: locations})
 
 	r := chi.NewRouter()
 	r.Use(middleware.RealIP)
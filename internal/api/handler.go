@@ -2,6 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
@@ -9,20 +12,46 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/schlubbi/score_board/internal/club"
+	"github.com/schlubbi/score_board/internal/compare"
+	"github.com/schlubbi/score_board/internal/geo"
+	"github.com/schlubbi/score_board/internal/highlights"
+	"github.com/schlubbi/score_board/internal/history"
 	"github.com/schlubbi/score_board/internal/model"
 	"github.com/schlubbi/score_board/internal/power"
+	"github.com/schlubbi/score_board/internal/profile"
+	"github.com/schlubbi/score_board/internal/ranking"
 	"github.com/schlubbi/score_board/internal/recommendation"
+	"github.com/schlubbi/score_board/internal/schedule"
 	"github.com/schlubbi/score_board/internal/service"
 )
 
 // Handler wires HTTP routes to the underlying service.
 type Handler struct {
-	svc *service.Service
+	svc         *service.Service
+	formula     power.Formula
+	clubAliases club.Aliases
+	locations   geo.Locations
+}
+
+// Options holds handler defaults that individual requests may override.
+type Options struct {
+	// Formula is the power formula used when a request does not pick one.
+	Formula power.Formula
+	// ClubAliases assigns teams to clubs where name heuristics fail.
+	ClubAliases club.Aliases
+	// Locations places clubs and teams for the travel term of group
+	// recommendations.
+	Locations geo.Locations
 }
 
 // NewHandler creates a new Handler.
-func NewHandler(svc *service.Service) *Handler {
-	return &Handler{svc: svc}
+func NewHandler(svc *service.Service, opts Options) *Handler {
+	formula := opts.Formula
+	if formula.Name == "" {
+		formula = power.DefaultFormula()
+	}
+	return &Handler{svc: svc, formula: formula, clubAliases: opts.ClubAliases, locations: opts.Locations}
 }
 
 // RegisterRoutes wires the handler to the provided router.
@@ -33,11 +62,30 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 		r.Get("/groups", h.handleListGroups)
 		r.Get("/groups/{groupID}", h.handleGroupDetail)
 		r.Get("/groups/{groupID}/teams/{teamID}/matches", h.handleTeamMatches)
+		r.Get("/groups/{groupID}/form", h.handleGroupForm)
+		r.Get("/formulas", h.handleFormulas)
 		r.Get("/overall", h.handleOverall)
 		r.Get("/overall/elo", h.handleOverallElo)
+		r.Get("/overall/glicko", h.handleOverallGlicko)
+		r.Get("/overall/calibrated", h.handleOverallCalibrated)
+		r.Get("/rankings", h.handleRankings)
+		r.Get("/rankings/diff", h.handleRankingDiff)
+		r.Get("/movers", h.handleMovers)
+		r.Get("/highlights", h.handleHighlights)
+		r.Get("/teams/{teamID}", h.handleTeamProfile)
+		r.Get("/teams/{teamID}/elo-history", h.handleTeamEloHistory)
+		r.Get("/home-advantage", h.handleHomeAdvantage)
+		r.Get("/compare", h.handleCompare)
+		r.Get("/clubs", h.handleListClubs)
+		r.Get("/clubs/{clubID}", h.handleClubDetail)
 		r.Get("/indoor/groups", h.handleIndoorGroups)
 		r.Get("/indoor/overall", h.handleIndoorOverall)
+		r.Get("/recommendations", h.handleRecommendations)
+		r.Post("/recommendations", h.handleConstrainedRecommendation)
 		r.Get("/recommendations/simple", h.handleSimpleRecommendation)
+		r.Get("/recommendations/stability", h.handleStability)
+		r.Post("/partitions/evaluate", h.handleEvaluatePartition)
+		r.Get("/schedule", h.handleSchedule)
 		r.Post("/refresh", h.handleRefresh)
 	})
 }
@@ -52,6 +100,17 @@ func (h *Handler) handleListGroups(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleGroupDetail(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	groupID := normalizeGroupID(chi.URLParam(r, "groupID"))
 	repo := h.svc.Repository()
 	snap, ok := repo.Snapshot(groupID)
@@ -75,8 +134,8 @@ func (h *Handler) handleGroupDetail(w http.ResponseWriter, r *http.Request) {
 		return teams[i].GoalsFor > teams[j].GoalsFor
 	})
 
-	groupMetrics := power.ComputeMetrics(teams)
-	overallMetrics := power.ComputeMetrics(repo.AllTeams())
+	groupMetrics := power.ComputeMetricsWithOptions(teams, snap.Matches, opts)
+	overallMetrics := power.ComputeMetricsWithOptions(repo.AllTeams(), repo.AllMatches(), opts)
 
 	teamPowers := make([]model.TeamPower, 0, len(teams))
 	for _, team := range teams {
@@ -86,6 +145,8 @@ func (h *Handler) handleGroupDetail(w http.ResponseWriter, r *http.Request) {
 			OverallMetrics: overallMetrics[team.TeamID],
 		})
 	}
+	attachForm(teamPowers, power.ComputeForm(snap.Matches, formOpts))
+	attachMovements(teamPowers, history.TableMovements(snap.Teams, snap.Matches))
 
 	resp := map[string]any{
 		"group": model.GroupSummary{
@@ -95,12 +156,61 @@ func (h *Handler) handleGroupDetail(w http.ResponseWriter, r *http.Request) {
 			LastUpdated: snap.ScrapedAt,
 			TeamCount:   len(snap.Teams),
 		},
-		"teams": teamPowers,
+		"formula": opts.Formula,
+		"teams":   teamPowers,
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// handleGroupForm serves the time-decayed form of every team in a group,
+// sorted by decayed points per game.
+func (h *Handler) handleGroupForm(w http.ResponseWriter, r *http.Request) {
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	groupID := normalizeGroupID(chi.URLParam(r, "groupID"))
+	snap, ok := h.svc.Repository().Snapshot(groupID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "group not found"})
+		return
+	}
+
+	forms := power.ComputeForm(snap.Matches, formOpts)
+
+	type teamForm struct {
+		Team model.TeamStats `json:"team"`
+		Form model.Form      `json:"form"`
+	}
+	entries := make([]teamForm, 0, len(snap.Teams))
+	for _, team := range snap.Teams {
+		entries = append(entries, teamForm{Team: team, Form: forms[team.TeamID]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Form.Points != entries[j].Form.Points {
+			return entries[i].Form.Points > entries[j].Form.Points
+		}
+		if entries[i].Form.GoalDiff != entries[j].Form.GoalDiff {
+			return entries[i].Form.GoalDiff > entries[j].Form.GoalDiff
+		}
+		return entries[i].Team.TeamName < entries[j].Team.TeamName
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"group": map[string]string{
+			"id":   snap.Config.ID,
+			"name": snap.Config.Name,
+		},
+		"halfLifeDays": formOpts.HalfLife,
+		"decayed":      power.FormDecays(snap.Matches),
+		"lastGames":    formOpts.LastN,
+		"teams":        entries,
+	})
+}
+
 func (h *Handler) handleTeamMatches(w http.ResponseWriter, r *http.Request) {
 	groupID := normalizeGroupID(chi.URLParam(r, "groupID"))
 	teamID := strings.TrimSpace(chi.URLParam(r, "teamID"))
@@ -141,14 +251,26 @@ func (h *Handler) handleTeamMatches(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleOverall(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	repo := h.svc.Repository()
 	teams := repo.AllTeams()
-	overallMetrics := power.ComputeMetrics(teams)
+	overallMetrics := power.ComputeMetricsWithOptions(teams, repo.AllMatches(), opts)
 
 	// Build group metrics per group for reference.
-	groupMetricMap := buildGroupMetricMap(repo.Snapshots())
+	groupMetricMap := buildGroupMetricMap(repo.Snapshots(), opts)
 
 	teamPowers := make([]model.TeamPower, 0, len(teams))
+	lowSample := make([]string, 0)
 	for _, team := range teams {
 		groupMetrics := groupMetricMap[team.GroupID][team.TeamID]
 		teamPowers = append(teamPowers, model.TeamPower{
@@ -156,7 +278,13 @@ func (h *Handler) handleOverall(w http.ResponseWriter, r *http.Request) {
 			GroupMetrics:   groupMetrics,
 			OverallMetrics: overallMetrics[team.TeamID],
 		})
+		if overallMetrics[team.TeamID].LowSample {
+			lowSample = append(lowSample, team.TeamID)
+		}
 	}
+	sort.Strings(lowSample)
+	attachForm(teamPowers, power.ComputeForm(repo.AllMatches(), formOpts))
+	attachMovements(teamPowers, history.PowerMovements(teams, repo.AllMatches(), opts))
 
 	sort.Slice(teamPowers, func(i, j int) bool {
 		pi := teamPowers[i].OverallMetrics.PowerScore
@@ -175,7 +303,14 @@ func (h *Handler) handleOverall(w http.ResponseWriter, r *http.Request) {
 
 	resp := map[string]any{
 		"updatedAt": repo.LastUpdated(),
-		"teams":     teamPowers,
+		"formula":   opts.Formula,
+		"margin":    opts.Margin,
+		"shrinkage": opts.Shrinkage,
+		"lowSample": map[string]any{
+			"minGames": power.LowSampleGames,
+			"teamIds":  lowSample,
+		},
+		"teams": teamPowers,
 	}
 
 	writeJSON(w, http.StatusOK, resp)
@@ -183,22 +318,17 @@ func (h *Handler) handleOverall(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) handleOverallElo(w http.ResponseWriter, r *http.Request) {
 	repo := h.svc.Repository()
-	snaps := repo.Snapshots()
-
-	allMatches := make([]model.MatchResult, 0)
-	for _, snap := range snaps {
-		allMatches = append(allMatches, snap.Matches...)
+	allMatches, params, err := h.leagueElo(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
 	}
 	if len(allMatches) == 0 {
 		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no matches available"})
 		return
 	}
 
-	sort.SliceStable(allMatches, func(i, j int) bool {
-		return allMatches[i].ID < allMatches[j].ID
-	})
-
-	elo := power.ComputeElo(allMatches, 1500, 20)
+	elo, _ := power.ComputeEloWithParams(allMatches, params)
 	teams := repo.AllTeams()
 
 	type teamElo struct {
@@ -229,12 +359,600 @@ func (h *Handler) handleOverallElo(w http.ResponseWriter, r *http.Request) {
 		return entries[i].Team.TeamName < entries[j].Team.TeamName
 	})
 
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt":     repo.LastUpdated(),
+		"margin":        params.Margin,
+		"homeAdvantage": params.HomeAdvantage,
+		"teams":         entries,
+	})
+}
+
+func (h *Handler) handleOverallGlicko(w http.ResponseWriter, r *http.Request) {
+	repo := h.svc.Repository()
+	snaps := repo.Snapshots()
+
+	allMatches := make([]model.MatchResult, 0)
+	for _, snap := range snaps {
+		allMatches = append(allMatches, snap.Matches...)
+	}
+	if len(allMatches) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no matches available"})
+		return
+	}
+
+	model.SortChronologically(allMatches)
+
+	cfg := power.DefaultGlickoConfig()
+	if period := strings.TrimSpace(r.URL.Query().Get("period")); period != "" {
+		switch power.GlickoPeriod(period) {
+		case power.GlickoPeriodWeek, power.GlickoPeriodMatchday:
+			cfg.Period = power.GlickoPeriod(period)
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "period must be week or matchday"})
+			return
+		}
+	}
+
+	glicko := power.ComputeGlicko(allMatches, cfg)
+	teams := repo.AllTeams()
+
+	type teamGlicko struct {
+		Team       model.TeamStats `json:"team"`
+		Rating     float64         `json:"rating"`
+		Deviation  float64         `json:"deviation"`
+		Volatility float64         `json:"volatility"`
+		Low        float64         `json:"low"`
+		High       float64         `json:"high"`
+		Games      int             `json:"games"`
+	}
+
+	entries := make([]teamGlicko, 0, len(teams))
+	for _, team := range teams {
+		res, ok := glicko[team.TeamID]
+		if !ok {
+			res = power.GlickoResult{Rating: cfg.InitialRating, Deviation: cfg.InitialDeviation, Volatility: cfg.InitialVolatility}
+		}
+		entries = append(entries, teamGlicko{
+			Team:       team,
+			Rating:     res.Rating,
+			Deviation:  res.Deviation,
+			Volatility: res.Volatility,
+			Low:        res.Rating - 2*res.Deviation,
+			High:       res.Rating + 2*res.Deviation,
+			Games:      res.Games,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		if entries[i].Deviation != entries[j].Deviation {
+			return entries[i].Deviation < entries[j].Deviation
+		}
+		if entries[i].Team.GoalDiff != entries[j].Team.GoalDiff {
+			return entries[i].Team.GoalDiff > entries[j].Team.GoalDiff
+		}
+		return entries[i].Team.TeamName < entries[j].Team.TeamName
+	})
+
 	writeJSON(w, http.StatusOK, map[string]any{
 		"updatedAt": repo.LastUpdated(),
+		"period":    cfg.Period,
 		"teams":     entries,
 	})
 }
 
+func (h *Handler) handleOverallCalibrated(w http.ResponseWriter, r *http.Request) {
+	margin, err := parseMargin(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	repo := h.svc.Repository()
+	teams := repo.AllTeams()
+	if len(teams) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no teams available"})
+		return
+	}
+
+	in := power.CalibrationInput{
+		LeagueTeams:   teams,
+		LeagueMatches: repo.AllMatches(),
+		Model:         power.DefaultGoalModelOptions(),
+	}
+	in.Model.Margin = margin
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		in.IndoorTeams = indoor.AllTeams()
+		in.IndoorMatches = indoor.AllMatches()
+	}
+
+	result := power.Calibrate(in)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"margin":    margin,
+		"teams":     result.Teams,
+		"groups":    result.Groups,
+		"report":    result.Report,
+	})
+}
+
+func (h *Handler) handleTeamEloHistory(w http.ResponseWriter, r *http.Request) {
+	teamID := strings.TrimSpace(chi.URLParam(r, "teamID"))
+	if teamID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "teamID required"})
+		return
+	}
+
+	repo := h.svc.Repository()
+	team, ok := findTeam(repo.AllTeams(), teamID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "team not found"})
+		return
+	}
+
+	allMatches, params, err := h.leagueElo(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	elo, history := power.ComputeEloWithParams(allMatches, params)
+	res := elo[teamID]
+	if res.Rating == 0 {
+		res.Rating = 1500
+	}
+	entries := history[teamID]
+	if entries == nil {
+		entries = []power.EloHistoryEntry{}
+	}
+
+	resp := map[string]any{
+		"team":          team,
+		"elo":           res.Rating,
+		"games":         res.Games,
+		"margin":        params.Margin,
+		"homeAdvantage": params.HomeAdvantage,
+		"history":       entries,
+	}
+	if move, ok := power.BiggestEloMove(entries); ok {
+		resp["biggestMove"] = move
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleHomeAdvantage reports the estimated home advantage for the league as a
+// whole, per league group and for the indoor tournament.
+func (h *Handler) handleHomeAdvantage(w http.ResponseWriter, r *http.Request) {
+	repo := h.svc.Repository()
+
+	type groupAdvantage struct {
+		GroupID       string              `json:"groupId"`
+		GroupName     string              `json:"groupName"`
+		HomeAdvantage power.HomeAdvantage `json:"homeAdvantage"`
+	}
+	groupsOut := make([]groupAdvantage, 0)
+	for _, snap := range repo.Snapshots() {
+		groupsOut = append(groupsOut, groupAdvantage{
+			GroupID:       snap.Config.ID,
+			GroupName:     snap.Config.Name,
+			HomeAdvantage: power.EstimateHomeAdvantage(snap.Matches),
+		})
+	}
+
+	competitions := map[string]any{
+		"league": power.EstimateHomeAdvantage(repo.AllMatches()),
+	}
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		competitions["indoor"] = power.EstimateHomeAdvantage(indoor.AllMatches())
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt":    repo.LastUpdated(),
+		"competitions": competitions,
+		"groups":       groupsOut,
+	})
+}
+
+// handleCompare compares two teams through their direct matches, common
+// opponents and opponent chains across league and indoor matches.
+func (h *Handler) handleCompare(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("teams"), ",")
+	if len(ids) != 2 || strings.TrimSpace(ids[0]) == "" || strings.TrimSpace(ids[1]) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "teams must be two comma-separated team IDs"})
+		return
+	}
+	maxLinks := compare.DefaultMaxLinks
+	if raw := strings.TrimSpace(r.URL.Query().Get("maxLinks")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 2 || v > 4 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "maxLinks must be between 2 and 4"})
+			return
+		}
+		maxLinks = v
+	}
+
+	ratings, err := h.rateAll(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	matches, mapping := h.combinedMatches()
+
+	type comparedTeam struct {
+		Team    model.TeamStats    `json:"team"`
+		Ratings *power.TeamRatings `json:"ratings,omitempty"`
+	}
+	teams := make([]comparedTeam, 0, 2)
+	for i, id := range ids {
+		id = strings.TrimSpace(id)
+		if leagueID, ok := mapping[id]; ok {
+			id = leagueID
+		}
+		team, ok := h.findAnyTeam(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "team not found: " + id})
+			return
+		}
+		ids[i] = id
+		entry := comparedTeam{Team: team}
+		if rating, ok := ratings[id]; ok {
+			entry.Ratings = &rating
+		}
+		teams = append(teams, entry)
+	}
+	if ids[0] == ids[1] {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "teams must differ"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt":  h.svc.Repository().LastUpdated(),
+		"teams":      teams,
+		"comparison": compare.Compare(matches, ids[0], ids[1], maxLinks),
+	})
+}
+
+// handleTeamProfile serves everything known about a team, across all league
+// groups and the indoor tournament.
+func (h *Handler) handleTeamProfile(w http.ResponseWriter, r *http.Request) {
+	teamID := strings.TrimSpace(chi.URLParam(r, "teamID"))
+	if teamID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "teamID required"})
+		return
+	}
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ratings, err := h.rateAll(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	repo := h.svc.Repository()
+	in := profile.Input{
+		League:  repo.Snapshots(),
+		Ratings: ratings,
+		History: history.Build(repo.AllTeams(), repo.AllMatches(), opts),
+		Form:    formOpts,
+	}
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		in.Indoor = indoor.Snapshots()
+	}
+
+	p, ok := profile.Build(in, teamID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "team not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"formula":   opts.Formula,
+		"profile":   p,
+	})
+}
+
+// handleRankings ranks all league teams by one method, selected with
+// ?method= (power, elo, glicko, calibrated or form).
+func (h *Handler) handleRankings(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	in, err := h.ratingInput(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	repo := h.svc.Repository()
+	result, err := ranking.Build(repo.AllTeams(), power.RateAll(in), r.URL.Query().Get("method"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	movements, err := ranking.Movements(in, result.Method)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ranking.AttachMovements(result, movements)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"formula":   opts.Formula,
+		"methods":   ranking.Methods(),
+		"ranking":   result,
+	})
+}
+
+// handleRankingDiff compares the rankings of methods ?a= (default power) and
+// ?b= (default elo) with rank correlations and per-team rank differences.
+func (h *Handler) handleRankingDiff(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ratings, err := h.rateAll(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	methodB := r.URL.Query().Get("b")
+	if strings.TrimSpace(methodB) == "" {
+		methodB = ranking.MethodElo
+	}
+
+	repo := h.svc.Repository()
+	teams := repo.AllTeams()
+	a, err := ranking.Build(teams, ratings, r.URL.Query().Get("a"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	b, err := ranking.Build(teams, ratings, methodB)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"formula":   opts.Formula,
+		"diff":      ranking.Compare(a, b),
+	})
+}
+
+// handleMovers lists the biggest rises and falls since the previous period
+// across all groups. ?method=table uses the group tables, any ranking method
+// (default power) the overall ranking; ?limit= caps each list (default 10).
+func (h *Handler) handleMovers(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	repo := h.svc.Repository()
+	teams := repo.AllTeams()
+	method := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("method")))
+	var movements map[string]model.Movement
+	if method == "table" {
+		movements = history.TableMovements(teams, repo.AllMatches())
+	} else {
+		in, err := h.ratingInput(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if method, err = ranking.ParseMethod(method); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if movements, err = ranking.Movements(in, method); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	rises, falls := history.Movers(teams, movements, limit)
+	resp := map[string]any{
+		"updatedAt": repo.LastUpdated(),
+		"method":    method,
+		"rises":     rises,
+		"falls":     falls,
+	}
+	// Group tables move on their own matchdays, see each movement's period.
+	if period := history.LatestPeriod(repo.AllMatches()); method != "table" && period != "" {
+		resp["period"] = period
+		resp["previousPeriod"] = history.PreviousPeriod(repo.AllMatches(), period)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleHighlights lists upsets and unusually large margins, most surprising
+// first, with weekly summaries. ?prior=elo|model picks the expectation the
+// outcome is judged by; ?limit= caps the highlights.
+func (h *Handler) handleHighlights(w http.ResponseWriter, r *http.Request) {
+	prior, err := highlights.ParsePrior(r.URL.Query().Get("prior"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	limit := 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+	in, err := h.ratingInput(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	opts := highlights.DefaultOptions()
+	opts.Prior = prior
+	opts.Elo = in.Elo
+	opts.Model = in.Model
+	feed := highlights.Build(in.LeagueMatches, opts)
+	if limit > 0 && len(feed.Highlights) > limit {
+		feed.Highlights = feed.Highlights[:limit]
+	}
+
+	resp := map[string]any{
+		"updatedAt":     h.svc.Repository().LastUpdated(),
+		"prior":         feed.Prior,
+		"homeAdvantage": in.Elo.HomeAdvantage,
+		"highlights":    feed.Highlights,
+	}
+	// Without match dates or matchdays there are no weeks to summarize.
+	if len(feed.Weeks) > 0 {
+		resp["weeks"] = feed.Weeks
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleListClubs lists every club with its teams, groups and combined
+// table stats.
+func (h *Handler) handleListClubs(w http.ResponseWriter, r *http.Request) {
+	in := h.clubInput()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": h.svc.Repository().LastUpdated(),
+		"clubs":     club.List(in),
+	})
+}
+
+// handleClubDetail serves a club dashboard: all of its teams with their
+// groups, ratings and form, combined stats and every match.
+func (h *Handler) handleClubDetail(w http.ResponseWriter, r *http.Request) {
+	clubID := strings.TrimSpace(chi.URLParam(r, "clubID"))
+	if clubID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "clubID required"})
+		return
+	}
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ratings, err := h.rateAll(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	in := h.clubInput()
+	in.Ratings = ratings
+	in.Form = formOpts
+	dashboard, ok := club.Build(in, clubID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "club not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updatedAt": h.svc.Repository().LastUpdated(),
+		"formula":   opts.Formula,
+		"club":      dashboard,
+	})
+}
+
+func (h *Handler) clubInput() club.Input {
+	in := club.Input{League: h.svc.Repository().Snapshots(), Aliases: h.clubAliases}
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		in.Indoor = indoor.Snapshots()
+	}
+	return in
+}
+
+// combinedMatches returns league and indoor matches, with indoor team IDs
+// replaced by the matching league IDs where a match was found.
+func (h *Handler) combinedMatches() ([]model.MatchResult, map[string]string) {
+	repo := h.svc.Repository()
+	matches := repo.AllMatches()
+	indoor := h.svc.IndoorRepository()
+	if indoor == nil {
+		return matches, map[string]string{}
+	}
+	mapping, _, _ := power.MatchIndoorTeams(repo.AllTeams(), indoor.AllTeams())
+	return append(matches, power.TranslateMatches(indoor.AllMatches(), mapping)...), mapping
+}
+
+// findAnyTeam looks a team up in the league and then in the indoor repository.
+func (h *Handler) findAnyTeam(teamID string) (model.TeamStats, bool) {
+	if team, ok := findTeam(h.svc.Repository().AllTeams(), teamID); ok {
+		return team, true
+	}
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		return findTeam(indoor.AllTeams(), teamID)
+	}
+	return model.TeamStats{}, false
+}
+
+// rateAll rates every league team with all methods, honouring the metric,
+// form and home advantage query parameters.
+func (h *Handler) rateAll(r *http.Request) (map[string]power.TeamRatings, error) {
+	in, err := h.ratingInput(r)
+	if err != nil {
+		return nil, err
+	}
+	return power.RateAll(in), nil
+}
+
+// ratingInput collects the data and parameters for power.RateAll.
+func (h *Handler) ratingInput(r *http.Request) (power.RatingInput, error) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		return power.RatingInput{}, err
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		return power.RatingInput{}, err
+	}
+
+	repo := h.svc.Repository()
+	in := power.RatingInput{
+		LeagueTeams:   repo.AllTeams(),
+		LeagueMatches: repo.AllMatches(),
+		Metrics:       opts,
+		Elo:           power.DefaultEloParams(),
+		Glicko:        power.DefaultGlickoConfig(),
+		Model:         power.DefaultGoalModelOptions(),
+		Form:          formOpts,
+	}
+	in.Elo.Margin = opts.Margin
+	in.Model.Margin = opts.Margin
+	in.Elo.HomeAdvantage, err = power.ResolveHomeAdvantage(r.URL.Query().Get("homeAdvantage"), in.LeagueMatches)
+	if err != nil {
+		return power.RatingInput{}, err
+	}
+	if indoor := h.svc.IndoorRepository(); indoor != nil {
+		in.IndoorTeams = indoor.AllTeams()
+		in.IndoorMatches = indoor.AllMatches()
+	}
+	return in, nil
+}
+
 func (h *Handler) handleIndoorGroups(w http.ResponseWriter, r *http.Request) {
 	repo := h.svc.IndoorRepository()
 	if repo == nil {
@@ -245,6 +963,17 @@ func (h *Handler) handleIndoorGroups(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleIndoorOverall(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	formOpts, err := parseFormOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	repo := h.svc.IndoorRepository()
 	if repo == nil {
 		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "indoor repository not configured"})
@@ -256,8 +985,8 @@ func (h *Handler) handleIndoorOverall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	overallMetrics := power.ComputeMetrics(teams)
-	groupMetricMap := buildGroupMetricMap(repo.Snapshots())
+	overallMetrics := power.ComputeMetricsWithOptions(teams, repo.AllMatches(), opts)
+	groupMetricMap := buildGroupMetricMap(repo.Snapshots(), opts)
 
 	teamPowers := make([]model.TeamPower, 0, len(teams))
 	for _, team := range teams {
@@ -268,6 +997,8 @@ func (h *Handler) handleIndoorOverall(w http.ResponseWriter, r *http.Request) {
 			OverallMetrics: overallMetrics[team.TeamID],
 		})
 	}
+	attachForm(teamPowers, power.ComputeForm(repo.AllMatches(), formOpts))
+	attachMovements(teamPowers, history.PowerMovements(teams, repo.AllMatches(), opts))
 
 	sort.Slice(teamPowers, func(i, j int) bool {
 		pi := teamPowers[i].OverallMetrics.PowerScore
@@ -286,6 +1017,8 @@ func (h *Handler) handleIndoorOverall(w http.ResponseWriter, r *http.Request) {
 
 	resp := map[string]any{
 		"updatedAt": repo.LastUpdated(),
+		"formula":   opts.Formula,
+		"margin":    opts.Margin,
 		"teams":     teamPowers,
 	}
 
@@ -293,54 +1026,334 @@ func (h *Handler) handleIndoorOverall(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleSimpleRecommendation(w http.ResponseWriter, r *http.Request) {
-	repo := h.svc.Repository()
-	teams := repo.AllTeams()
-	if len(teams) == 0 {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no teams available"})
+	h.writeRecommendation(w, r, recommendation.StrategySimple, nil)
+}
+
+// handleRecommendations suggests next season's groups. ?strategy=balanced (the
+// default) optimizes the groups to equal average strength, with ?seed= and
+// ?iterations= for the search; ?strategy=simple fills them in ranking order;
+// ?strategy=tiered forms ?tiers= strength tiers of ?groupsPerTier= balanced
+// groups each.
+func (h *Handler) handleRecommendations(w http.ResponseWriter, r *http.Request) {
+	strategy, err := recommendation.ParseStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
+	h.writeRecommendation(w, r, strategy, nil)
+}
 
-	overallMetrics := power.ComputeMetrics(teams)
-	groupMetricMap := buildGroupMetricMap(repo.Snapshots())
+// handleConstrainedRecommendation solves the balanced grouping under the
+// constraints in the JSON body (pins, pairs apart or together, clubs kept
+// together, maximum group sizes) and reports the status of each constraint.
+func (h *Handler) handleConstrainedRecommendation(w http.ResponseWriter, r *http.Request) {
+	strategy, err := recommendation.ParseStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if strategy != recommendation.StrategyBalanced {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "constraints require strategy=balanced"})
+		return
+	}
 
-	teamPowers := make([]model.TeamPower, 0, len(teams))
-	for _, team := range teams {
-		gMetrics := groupMetricMap[team.GroupID][team.TeamID]
-		teamPowers = append(teamPowers, model.TeamPower{
-			Team:           team,
-			GroupMetrics:   gMetrics,
-			OverallMetrics: overallMetrics[team.TeamID],
-		})
+	var constraints recommendation.Constraints
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&constraints); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid constraints: " + err.Error()})
+		return
 	}
+	h.writeRecommendation(w, r, strategy, &constraints)
+}
 
-	sort.Slice(teamPowers, func(i, j int) bool {
-		pi := teamPowers[i].OverallMetrics.PowerScore
-		pj := teamPowers[j].OverallMetrics.PowerScore
-		if pi != pj {
-			return pi > pj
-		}
-		if teamPowers[i].Team.GoalDiff != teamPowers[j].Team.GoalDiff {
-			return teamPowers[i].Team.GoalDiff > teamPowers[j].Team.GoalDiff
-		}
-		if teamPowers[i].Team.Points != teamPowers[j].Team.Points {
-			return teamPowers[i].Team.Points > teamPowers[j].Team.Points
-		}
-		return teamPowers[i].Team.TeamName < teamPowers[j].Team.TeamName
-	})
+// writeRecommendation answers with the grouping of the given strategy. When
+// constraints are given, the balanced strategy honors them.
+func (h *Handler) writeRecommendation(w http.ResponseWriter, r *http.Request, strategy string, constraints *recommendation.Constraints) {
+	resp, status, err := h.recommend(r, strategy, constraints)
+	if err != nil {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// recommend builds the recommendation response, or an error with its status.
+func (h *Handler) recommend(r *http.Request, strategy string, constraints *recommendation.Constraints) (map[string]any, int, error) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	balancedOpts, err := parseBalancedOptions(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	repo := h.svc.Repository()
+	teams := club.Assign(repo.AllTeams(), h.clubAliases)
+	if len(teams) == 0 {
+		return nil, http.StatusServiceUnavailable, errors.New("no teams available")
+	}
+	teamPowers := recommendation.RankTeams(teams, repo.AllMatches(), repo.Snapshots(), opts)
+	balancedOpts.Locations = h.locations.Locate(teams)
 
 	groupCount := len(h.svc.Groups())
 	if groupCount == 0 {
 		groupCount = 1
 	}
 
-	groups := recommendation.SimpleBalancedGroups(teamPowers, groupCount)
 	resp := map[string]any{
 		"generatedAt": time.Now().UTC(),
+		"strategy":    strategy,
 		"totalTeams":  len(teamPowers),
 		"groupCount":  groupCount,
+		"formula":     opts.Formula,
+	}
+	switch strategy {
+	case recommendation.StrategyTiered:
+		tieredOpts, err := parseTieredOptions(r, groupCount)
+		if err == nil {
+			err = tieredOpts.Validate(len(teamPowers))
+		}
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		groups, tiers := recommendation.TieredGroups(teamPowers, tieredOpts, balancedOpts)
+		resp["groupCount"] = len(groups)
+		resp["groups"] = groups
+		resp["tiers"] = tiers
+		resp["seed"] = balancedOpts.Seed
+		resp["iterations"] = balancedOpts.Iterations
+	case recommendation.StrategyMinimal:
+		minimalOpts, err := parseMinimalOptions(r)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		result := recommendation.MinimalChange(teamPowers, h.svc.Groups(), minimalOpts)
+		resp["groupCount"] = len(result.Groups)
+		resp["groups"] = result.Groups
+		resp["objective"] = result.Objective
+		resp["minimal"] = result
+	case recommendation.StrategyDraw:
+		drawOpts, err := parseDrawOptions(r)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		draw := recommendation.Draw(teamPowers, groupCount, drawOpts.Seed)
+		resp["groups"] = draw.Groups
+		resp["objective"] = draw.Objective
+		resp["seed"] = draw.Seed
+		resp["pots"] = draw.Pots
+		resp["log"] = draw.Log
+		resp["simulation"] = recommendation.SimulateDraws(teamPowers, groupCount, drawOpts)
+	case recommendation.StrategyBalanced:
+		var c recommendation.Constraints
+		if constraints != nil {
+			c = *constraints
+		}
+		groups, objective, report := recommendation.ConstrainedGroups(teamPowers, groupCount, c, balancedOpts)
+		if constraints != nil {
+			resp["constraints"] = report
+		}
+		resp["groups"] = groups
+		resp["objective"] = objective
+		resp["seed"] = balancedOpts.Seed
+		resp["iterations"] = balancedOpts.Iterations
+		// The simple split is the starting point; its objective shows the gain.
+		simple := recommendation.SimpleBalancedGroups(teamPowers, groupCount)
+		resp["baseline"] = recommendation.Measure(simple, recommendation.TargetSizes(len(teamPowers), groupCount), balancedOpts.Locations)
+	default:
+		groups := recommendation.SimpleBalancedGroups(teamPowers, groupCount)
+		resp["groups"] = groups
+		resp["objective"] = recommendation.Measure(groups, recommendation.TargetSizes(len(teamPowers), groupCount), balancedOpts.Locations)
+	}
+	groups, _ := resp["groups"].([]recommendation.Group)
+	resp["quality"] = recommendation.Evaluate(groups, h.partitionModel(opts), recommendation.DefaultQualityOptions())
+	return resp, http.StatusOK, nil
+}
+
+// handleStability reruns a recommendation on bootstrap resamples of the
+// matches (?resamples=, ?resampleSeed=) and reports how often every team
+// stays in its group and which pairs share a group in at least ?together= of
+// them.
+func (h *Handler) handleStability(w http.ResponseWriter, r *http.Request) {
+	strategy, err := recommendation.ParseStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if strategy == recommendation.StrategyDraw {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a draw is random by design, see its simulation instead"})
+		return
+	}
+	stabilityOpts, err := parseStabilityOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	resp, status, err := h.recommend(r, strategy, nil)
+	if err != nil {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	regroup, err := h.regroup(r, strategy)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	groups, _ := resp["groups"].([]recommendation.Group)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"generatedAt": time.Now().UTC(),
+		"strategy":    strategy,
+		"groupCount":  len(groups),
+		"formula":     resp["formula"],
 		"groups":      groups,
+		"stability":   recommendation.Stability(groups, h.svc.Repository().AllMatches(), regroup, stabilityOpts),
+	})
+}
+
+// regroup returns the strategy with the request's options as a function of
+// the matches, see recommendation.NewRegroup.
+func (h *Handler) regroup(r *http.Request, strategy string) (recommendation.Regroup, error) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		return nil, err
 	}
-	writeJSON(w, http.StatusOK, resp)
+	var strategyOpts recommendation.StrategyOptions
+	if strategyOpts.Balanced, err = parseBalancedOptions(r); err != nil {
+		return nil, err
+	}
+	repo := h.svc.Repository()
+	teams := club.Assign(repo.AllTeams(), h.clubAliases)
+	strategyOpts.Balanced.Locations = h.locations.Locate(teams)
+	configs := h.svc.Groups()
+
+	switch strategy {
+	case recommendation.StrategyTiered:
+		strategyOpts.Tiered, err = parseTieredOptions(r, max(len(configs), 1))
+	case recommendation.StrategyMinimal:
+		strategyOpts.Minimal, err = parseMinimalOptions(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return recommendation.NewRegroup(strategy, teams, repo.Snapshots(), configs, opts, strategyOpts), nil
+}
+
+// handleSchedule generates the match schedule of every group of a
+// recommendation as JSON, CSV (format=csv) or iCal (format=ics).
+func (h *Handler) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	strategy, err := recommendation.ParseStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	scheduleOpts, err := parseScheduleOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format != "" && format != "json" && format != "csv" && format != "ics" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json, csv or ics"})
+		return
+	}
+
+	resp, status, err := h.recommend(r, strategy, nil)
+	if err != nil {
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	groups, _ := resp["groups"].([]recommendation.Group)
+	plan, err := schedule.Generate(schedule.FromRecommendation(groups), scheduleOpts)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=schedule_"+strategy+".csv")
+		_ = schedule.WriteCSV(w, plan)
+	case "ics":
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=schedule_"+strategy+".ics")
+		_ = schedule.WriteICal(w, plan, "Schedule ("+strategy+")", time.Now())
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{
+			"generatedAt": time.Now().UTC(),
+			"strategy":    strategy,
+			"schedule":    plan,
+		})
+	}
+}
+
+// partitionRequest is the body of POST /api/partitions/evaluate: the team IDs
+// of every group. Without groups, the current groups are evaluated.
+type partitionRequest struct {
+	Groups [][]string `json:"groups"`
+}
+
+// handleEvaluatePartition scores an assignment of teams to groups.
+func (h *Handler) handleEvaluatePartition(w http.ResponseWriter, r *http.Request) {
+	opts, err := h.metricOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	qualityOpts, err := parseQualityOptions(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var req partitionRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid partition: " + err.Error()})
+		return
+	}
+
+	repo := h.svc.Repository()
+	teams := club.Assign(repo.AllTeams(), h.clubAliases)
+	teamPowers := recommendation.RankTeams(teams, repo.AllMatches(), repo.Snapshots(), opts)
+	source := "request"
+	var groups []recommendation.Group
+	if len(req.Groups) == 0 {
+		source = "current"
+		groups = recommendation.CurrentGroups(teamPowers, h.svc.Groups())
+	} else if groups, err = recommendation.Partition(teamPowers, req.Groups); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"generatedAt": time.Now().UTC(),
+		"source":      source,
+		"groupCount":  len(groups),
+		"formula":     opts.Formula,
+		"groups":      groups,
+		"quality":     recommendation.Evaluate(groups, h.partitionModel(opts), qualityOpts),
+	})
+}
+
+// partitionModel fits the goal model that predicts the pairings of a
+// partition, see recommendation.PartitionModel.
+func (h *Handler) partitionModel(opts power.MetricOptions) power.GoalModel {
+	var indoor []model.GroupSnapshot
+	if repo := h.svc.IndoorRepository(); repo != nil {
+		indoor = repo.Snapshots()
+	}
+	return recommendation.PartitionModel(h.svc.Repository().Snapshots(), indoor, opts)
+}
+
+func (h *Handler) handleFormulas(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"default": h.formula,
+		"presets": power.FormulaPresets(),
+	})
 }
 
 func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
@@ -361,14 +1374,265 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
-func buildGroupMetricMap(snaps []model.GroupSnapshot) map[string]map[string]model.MetricSet {
+func buildGroupMetricMap(snaps []model.GroupSnapshot, opts power.MetricOptions) map[string]map[string]model.MetricSet {
 	groupMetricMap := make(map[string]map[string]model.MetricSet)
 	for _, snap := range snaps {
-		groupMetricMap[snap.Config.ID] = power.ComputeMetrics(snap.Teams)
+		groupMetricMap[snap.Config.ID] = power.ComputeMetricsWithOptions(snap.Teams, snap.Matches, opts)
 	}
 	return groupMetricMap
 }
 
+// metricOptions resolves the power formula, margin dampening and shrinkage for
+// a request. The formula starts from the handler default and may be overridden
+// with the formula (preset), weights and norm query parameters; shrinkage is
+// enabled with shrink=group|league and an optional priorGames weight.
+func (h *Handler) metricOptions(r *http.Request) (power.MetricOptions, error) {
+	opts := power.DefaultMetricOptions()
+	q := r.URL.Query()
+
+	formula, err := power.ResolveFormula(h.formula, q.Get("formula"), q.Get("weights"), q.Get("norm"))
+	if err != nil {
+		return opts, err
+	}
+	margin, err := parseMargin(r)
+	if err != nil {
+		return opts, err
+	}
+	shrinkage, err := power.ParseShrinkageConfig(q.Get("shrink"), q.Get("priorGames"))
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Formula = formula
+	opts.Margin = margin
+	opts.Shrinkage = shrinkage
+	return opts, nil
+}
+
+func parseFormOptions(r *http.Request) (power.FormOptions, error) {
+	q := r.URL.Query()
+	return power.ParseFormOptions(q.Get("halfLife"), q.Get("last"))
+}
+
+// attachForm sets the form of every team that has one.
+func attachForm(teamPowers []model.TeamPower, forms map[string]model.Form) {
+	for i := range teamPowers {
+		if form, ok := forms[teamPowers[i].Team.TeamID]; ok {
+			teamPowers[i].Form = &form
+		}
+	}
+}
+
+func attachMovements(teamPowers []model.TeamPower, movements map[string]model.Movement) {
+	for i := range teamPowers {
+		if movement, ok := movements[teamPowers[i].Team.TeamID]; ok {
+			teamPowers[i].Movement = &movement
+		}
+	}
+}
+
+func parseBalancedOptions(r *http.Request) (recommendation.BalancedOptions, error) {
+	q := r.URL.Query()
+	opts := recommendation.DefaultBalancedOptions()
+	if raw := strings.TrimSpace(q.Get("seed")); raw != "" {
+		seed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return opts, errors.New("seed must be an integer")
+		}
+		opts.Seed = seed
+	}
+	if raw := strings.TrimSpace(q.Get("iterations")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > 1000000 {
+			return opts, errors.New("iterations must be an integer between 0 and 1000000")
+		}
+		opts.Iterations = n
+	}
+	if raw := strings.TrimSpace(q.Get("travelWeight")); raw != "" {
+		weight, err := strconv.ParseFloat(raw, 64)
+		if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return opts, errors.New("travelWeight must be a non-negative number")
+		}
+		opts.TravelWeight = weight
+	}
+	return opts, nil
+}
+
+func parseTieredOptions(r *http.Request, groupCount int) (recommendation.TieredOptions, error) {
+	q := r.URL.Query()
+	opts := recommendation.DefaultTieredOptions(groupCount)
+	for name, target := range map[string]*int{"tiers": &opts.Tiers, "groupsPerTier": &opts.GroupsPerTier} {
+		raw := strings.TrimSpace(q.Get(name))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return opts, errors.New(name + " must be a positive integer")
+		}
+		*target = n
+	}
+	return opts, nil
+}
+
+func parseScheduleOptions(r *http.Request) (schedule.Options, error) {
+	q := r.URL.Query()
+	opts := schedule.DefaultOptions(time.Now())
+	if raw := strings.TrimSpace(q.Get("start")); raw != "" {
+		start, err := time.Parse(schedule.DateLayout, raw)
+		if err != nil {
+			return opts, errors.New("start must be a date (YYYY-MM-DD)")
+		}
+		opts.Start = start
+	}
+	for name, target := range map[string]*int{"interval": &opts.IntervalDays, "pitches": &opts.DefaultPitches} {
+		raw := strings.TrimSpace(q.Get(name))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return opts, errors.New(name + " must be a positive integer")
+		}
+		*target = n
+	}
+	blackouts, err := schedule.ParseDates(q.Get("blackouts"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Blackouts = blackouts
+	if raw := strings.TrimSpace(q.Get("double")); raw != "" {
+		double, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, errors.New("double must be true or false")
+		}
+		opts.Double = double
+	}
+	return opts, nil
+}
+
+func parseQualityOptions(r *http.Request) (recommendation.QualityOptions, error) {
+	q := r.URL.Query()
+	opts := recommendation.DefaultQualityOptions()
+	for name, target := range map[string]*int{"closeMargin": &opts.CloseMargin, "topTeams": &opts.TopTeams} {
+		raw := strings.TrimSpace(q.Get(name))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return opts, errors.New(name + " must be a non-negative integer")
+		}
+		*target = n
+	}
+	return opts, nil
+}
+
+func parseStabilityOptions(r *http.Request) (recommendation.StabilityOptions, error) {
+	q := r.URL.Query()
+	opts := recommendation.DefaultStabilityOptions()
+	if raw := strings.TrimSpace(q.Get("resamples")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return opts, errors.New("resamples must be an integer")
+		}
+		opts.Resamples = n
+	}
+	if raw := strings.TrimSpace(q.Get("resampleSeed")); raw != "" {
+		seed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return opts, errors.New("resampleSeed must be an integer")
+		}
+		opts.Seed = seed
+	}
+	if raw := strings.TrimSpace(q.Get("together")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return opts, errors.New("together must be a number")
+		}
+		opts.Together = v
+	}
+	return opts, opts.Validate()
+}
+
+func parseDrawOptions(r *http.Request) (recommendation.DrawOptions, error) {
+	q := r.URL.Query()
+	opts := recommendation.DefaultDrawOptions()
+	if raw := strings.TrimSpace(q.Get("seed")); raw != "" {
+		seed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return opts, errors.New("seed must be an integer")
+		}
+		opts.Seed = seed
+	}
+	if raw := strings.TrimSpace(q.Get("simulations")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return opts, errors.New("simulations must be an integer")
+		}
+		opts.Simulations = n
+	}
+	return opts, opts.Validate()
+}
+
+func parseMinimalOptions(r *http.Request) (recommendation.MinimalOptions, error) {
+	q := r.URL.Query()
+	goal, err := recommendation.ParseGoal(q.Get("goal"))
+	if err != nil {
+		return recommendation.MinimalOptions{}, err
+	}
+	opts := recommendation.DefaultMinimalOptions(goal)
+	if raw := strings.TrimSpace(q.Get("target")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return opts, errors.New("target must be a number")
+		}
+		opts.Target = v
+	}
+	for name, target := range map[string]*int{"maxMoves": &opts.MaxMoves, "sizeSlack": &opts.SizeSlack} {
+		raw := strings.TrimSpace(q.Get(name))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return opts, errors.New(name + " must be an integer")
+		}
+		*target = n
+	}
+	return opts, opts.Validate()
+}
+
+// leagueElo returns all matches in the order they were played together with
+// the Elo parameters of the request, so the overall Elo ranking and a team's
+// Elo history replay the same matches the same way.
+func (h *Handler) leagueElo(r *http.Request) ([]model.MatchResult, power.EloParams, error) {
+	params := power.DefaultEloParams()
+	margin, err := parseMargin(r)
+	if err != nil {
+		return nil, params, err
+	}
+
+	matches := make([]model.MatchResult, 0)
+	for _, snap := range h.svc.Repository().Snapshots() {
+		matches = append(matches, snap.Matches...)
+	}
+	model.SortChronologically(matches)
+
+	homeAdvantage, err := power.ResolveHomeAdvantage(r.URL.Query().Get("homeAdvantage"), matches)
+	if err != nil {
+		return nil, params, err
+	}
+	params.Margin = margin
+	params.HomeAdvantage = homeAdvantage
+	return matches, params, nil
+}
+
+func parseMargin(r *http.Request) (power.MarginConfig, error) {
+	q := r.URL.Query()
+	return power.ParseMarginConfig(q.Get("margin"), q.Get("marginCap"))
+}
+
 func filterTeamMatches(matches []model.MatchResult, teamID string) []model.MatchResult {
 	result := make([]model.MatchResult, 0)
 	for _, match := range matches {
@@ -379,6 +1643,15 @@ func filterTeamMatches(matches []model.MatchResult, teamID string) []model.Match
 	return result
 }
 
+func findTeam(teams []model.TeamStats, teamID string) (model.TeamStats, bool) {
+	for _, team := range teams {
+		if team.TeamID == teamID {
+			return team, true
+		}
+	}
+	return model.TeamStats{}, false
+}
+
 func normalizeGroupID(input string) string {
 	input = strings.TrimSpace(strings.ToLower(input))
 	if input == "" {
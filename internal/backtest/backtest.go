@@ -0,0 +1,311 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// probabilityFloor keeps log-loss finite for overconfident predictions.
+const probabilityFloor = 1e-6
+
+// Options controls which matches are scored and which parameters are searched.
+type Options struct {
+	// MinPriorGames skips scoring a match until both teams have played this
+	// many matches, so every method has something to go on.
+	MinPriorGames int
+	KFactors      []float64
+	MarginCaps    []float64
+	// HomeAdvantages are the Elo home bonuses tried for Elo and Glicko.
+	HomeAdvantages []float64
+	// WeightStep is the grid resolution for the power score weights.
+	WeightStep float64
+	// Scales map power score differences to win probabilities.
+	Scales []float64
+}
+
+// DefaultOptions returns the grid used by cmd/backtest.
+func DefaultOptions() Options {
+	return Options{
+		MinPriorGames:  1,
+		KFactors:       []float64{10, 20, 30, 40, 50, 60},
+		MarginCaps:     []float64{1, 2, 3, 4, 5, 6},
+		HomeAdvantages: []float64{0, 25, 50, 75, 100},
+		WeightStep:     0.1,
+		Scales:         []float64{1, 2, 4, 6, 8},
+	}
+}
+
+// Score summarizes how well one method/parameter set predicted the season.
+type Score struct {
+	Method   string             `json:"method"`
+	Label    string             `json:"label"`
+	Params   map[string]float64 `json:"params,omitempty"`
+	Matches  int                `json:"matches"`
+	LogLoss  float64            `json:"logLoss"`
+	Brier    float64            `json:"brier"`
+	Accuracy float64            `json:"accuracy"`
+}
+
+// Report is the full backtest outcome. Results are sorted by log-loss.
+type Report struct {
+	TotalMatches  int              `json:"totalMatches"`
+	ScoredMatches int              `json:"scoredMatches"`
+	Draws         int              `json:"draws"`
+	Results       []Score          `json:"results"`
+	Best          map[string]Score `json:"best"`
+}
+
+type outcome struct {
+	actual float64
+	scored bool
+}
+
+// Run replays matches in chronological order. Each match is predicted from the
+// matches before it only, then scored by log-loss, Brier score and accuracy.
+// Predictions are the home team's expected score, where a draw counts as 0.5.
+func Run(matches []model.MatchResult, opts Options) Report {
+	played := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if m.Played() && m.HomeTeamID != "" && m.AwayTeamID != "" {
+			played = append(played, m)
+		}
+	}
+	model.SortChronologically(played)
+
+	outcomes := make([]outcome, len(played))
+	gamesPlayed := make(map[string]int)
+	report := Report{TotalMatches: len(played), Best: make(map[string]Score)}
+	for i, m := range played {
+		actual := 0.5
+		switch {
+		case m.HomeScore > m.AwayScore:
+			actual = 1
+		case m.HomeScore < m.AwayScore:
+			actual = 0
+		}
+		scored := gamesPlayed[m.HomeTeamID] >= opts.MinPriorGames && gamesPlayed[m.AwayTeamID] >= opts.MinPriorGames
+		outcomes[i] = outcome{actual: actual, scored: scored}
+		if scored {
+			report.ScoredMatches++
+			if actual == 0.5 {
+				report.Draws++
+			}
+		}
+		gamesPlayed[m.HomeTeamID]++
+		gamesPlayed[m.AwayTeamID]++
+	}
+
+	baseline := make([]float64, len(played))
+	for i := range baseline {
+		baseline[i] = 0.5
+	}
+	report.Results = append(report.Results, score("baseline", "coin flip", nil, baseline, outcomes))
+
+	homeAdvantages := opts.HomeAdvantages
+	if len(homeAdvantages) == 0 {
+		homeAdvantages = []float64{0}
+	}
+
+	for _, k := range opts.KFactors {
+		for _, marginCap := range opts.MarginCaps {
+			for _, ha := range homeAdvantages {
+				params := power.DefaultEloParams()
+				params.KFactor = k
+				params.MarginCap = marginCap
+				params.HomeAdvantage = ha
+				preds := predictElo(played, params)
+				label := fmt.Sprintf("K=%g cap=%g home=%g", k, marginCap, ha)
+				report.Results = append(report.Results, score("elo", label, map[string]float64{"kFactor": k, "marginCap": marginCap, "homeAdvantage": ha}, preds, outcomes))
+			}
+		}
+	}
+
+	for _, ha := range homeAdvantages {
+		label := fmt.Sprintf("glicko-2 weekly home=%g", ha)
+		report.Results = append(report.Results, score("glicko", label, map[string]float64{"homeAdvantage": ha}, predictGlicko(played, ha), outcomes))
+	}
+
+	components := powerComponents(played)
+	for _, weights := range weightGrid(opts.WeightStep) {
+		for _, scale := range opts.Scales {
+			preds := make([]float64, len(played))
+			for i, c := range components {
+				preds[i] = 0.5
+				if !c.ok {
+					continue
+				}
+				diff := weights.Score(c.home) - weights.Score(c.away)
+				preds[i] = 1 / (1 + math.Exp(-scale*diff))
+			}
+			label := fmt.Sprintf("%.1f/%.1f/%.1f scale=%g", weights.Offense, weights.Defense, weights.Dominance, scale)
+			params := map[string]float64{
+				"offense":   weights.Offense,
+				"defense":   weights.Defense,
+				"dominance": weights.Dominance,
+				"scale":     scale,
+			}
+			report.Results = append(report.Results, score("power", label, params, preds, outcomes))
+		}
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].LogLoss < report.Results[j].LogLoss
+	})
+	for _, res := range report.Results {
+		if _, ok := report.Best[res.Method]; !ok {
+			report.Best[res.Method] = res
+		}
+	}
+
+	return report
+}
+
+func predictElo(matches []model.MatchResult, params power.EloParams) []float64 {
+	rater := power.NewEloRater(params)
+	preds := make([]float64, len(matches))
+	for i, m := range matches {
+		preds[i] = rater.Expected(m)
+		rater.Apply(m)
+	}
+	return preds
+}
+
+// predictGlicko predicts each match from the Glicko-2 ratings of the matchdays
+// before it. The home bonus only shifts the prediction, the ratings themselves
+// are symmetric.
+func predictGlicko(matches []model.MatchResult, homeAdvantage float64) []float64 {
+	cfg := power.DefaultGlickoConfig()
+	initial := power.GlickoResult{Rating: cfg.InitialRating, Deviation: cfg.InitialDeviation, Volatility: cfg.InitialVolatility}
+
+	preds := make([]float64, len(matches))
+	var ratings map[string]power.GlickoResult
+	for i, m := range matches {
+		if newMatchday(matches, i) {
+			ratings = power.ComputeGlicko(matches[:i], cfg)
+		}
+		home, ok := ratings[m.HomeTeamID]
+		if !ok {
+			home = initial
+		}
+		away, ok := ratings[m.AwayTeamID]
+		if !ok {
+			away = initial
+		}
+		if !m.Neutral {
+			home.Rating += homeAdvantage
+		}
+		preds[i] = power.GlickoExpected(home, away, cfg.InitialRating)
+	}
+	return preds
+}
+
+// newMatchday reports whether matches[i] starts a new date/matchday, i.e. when
+// the ratings derived from earlier matches need to be recomputed.
+func newMatchday(matches []model.MatchResult, i int) bool {
+	if i == 0 {
+		return true
+	}
+	return matches[i-1].MatchDate != matches[i].MatchDate || matches[i-1].MatchdayTag != matches[i].MatchdayTag
+}
+
+type matchComponents struct {
+	home model.NormalizedSet
+	away model.NormalizedSet
+	ok   bool
+}
+
+// powerComponents computes the normalized power metrics of both teams from the
+// matches played before each match. The weights only combine these components,
+// so they can be searched without recomputing the metrics.
+func powerComponents(matches []model.MatchResult) []matchComponents {
+	teams := make([]model.TeamStats, 0)
+	seen := make(map[string]struct{})
+	for _, m := range matches {
+		for _, id := range []string{m.HomeTeamID, m.AwayTeamID} {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			teams = append(teams, model.TeamStats{TeamID: id})
+		}
+	}
+
+	out := make([]matchComponents, len(matches))
+	var metrics map[string]model.MetricSet
+	var stats map[string]model.TeamStats
+	for i, m := range matches {
+		if newMatchday(matches, i) {
+			prior := model.ApplyMatchAggregates(teams, matches[:i])
+			metrics = power.ComputeMetrics(prior)
+			stats = make(map[string]model.TeamStats, len(prior))
+			for _, t := range prior {
+				stats[t.TeamID] = t
+			}
+		}
+		if stats[m.HomeTeamID].Games == 0 || stats[m.AwayTeamID].Games == 0 {
+			continue
+		}
+		out[i] = matchComponents{
+			home: metrics[m.HomeTeamID].Normalized,
+			away: metrics[m.AwayTeamID].Normalized,
+			ok:   true,
+		}
+	}
+	return out
+}
+
+func weightGrid(step float64) []power.Weights {
+	if step <= 0 || step > 1 {
+		step = 0.1
+	}
+	steps := int(math.Round(1 / step))
+	grid := make([]power.Weights, 0)
+	for o := 0; o <= steps; o++ {
+		for d := 0; o+d <= steps; d++ {
+			grid = append(grid, power.Weights{
+				Offense:   float64(o) / float64(steps),
+				Defense:   float64(d) / float64(steps),
+				Dominance: float64(steps-o-d) / float64(steps),
+			})
+		}
+	}
+	return grid
+}
+
+func score(method, label string, params map[string]float64, preds []float64, outcomes []outcome) Score {
+	s := Score{Method: method, Label: label, Params: params}
+	var logLoss, brier, correct float64
+	decisive := 0
+	for i, o := range outcomes {
+		if !o.scored {
+			continue
+		}
+		p := math.Min(math.Max(preds[i], probabilityFloor), 1-probabilityFloor)
+		s.Matches++
+		logLoss -= o.actual*math.Log(p) + (1-o.actual)*math.Log(1-p)
+		brier += (p - o.actual) * (p - o.actual)
+
+		if o.actual == 0.5 {
+			continue
+		}
+		decisive++
+		switch {
+		case p == 0.5:
+			correct += 0.5
+		case (p > 0.5) == (o.actual == 1):
+			correct++
+		}
+	}
+	if s.Matches > 0 {
+		s.LogLoss = logLoss / float64(s.Matches)
+		s.Brier = brier / float64(s.Matches)
+	}
+	if decisive > 0 {
+		s.Accuracy = correct / float64(decisive)
+	}
+	return s
+}
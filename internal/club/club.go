@@ -0,0 +1,364 @@
+package club
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+	"github.com/schlubbi/score_board/internal/profile"
+)
+
+// Aliases maps a team or club name to the name of the club it belongs to,
+// e.g. {"JSG Nieste/Staufenberg": "TSV Nieste"}. Keys are stored as nameKey,
+// so they match case-insensitively.
+type Aliases map[string]string
+
+// LoadAliases reads aliases from a JSON object of alias to club name.
+func LoadAliases(path string) (Aliases, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse club aliases %s: %w", path, err)
+	}
+	aliases := make(Aliases, len(raw))
+	for alias, name := range raw {
+		alias, name = nameKey(alias), strings.TrimSpace(name)
+		if alias == "" || name == "" {
+			return nil, fmt.Errorf("club aliases %s: empty alias or club name", path)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// lookup returns the club name for a team, trying the full team name before
+// the club part of it. The keys are normalized by LoadAliases.
+func (a Aliases) lookup(teamName string) (string, bool) {
+	for _, key := range []string{nameKey(teamName), nameKey(model.ClubName(teamName))} {
+		if name, ok := a[key]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Resolve identifies the clubs of the given teams. Teams are grouped by
+// their fussball.de club ID; teams without one join the club of the same
+// name or form a club of their own, keyed by a slug of the name. Aliases take
+// precedence over both. Earlier teams win when names differ, so callers
+// should list league teams first.
+func Resolve(teams []model.TeamStats, aliases Aliases) []model.Club {
+	clubs, _ := resolve(teams, aliases)
+	return clubs
+}
+
+// Assign returns a copy of teams with ClubID set to the resolved club.
+func Assign(teams []model.TeamStats, aliases Aliases) []model.TeamStats {
+	_, clubOf := resolve(teams, aliases)
+	out := make([]model.TeamStats, len(teams))
+	for i, team := range teams {
+		team.ClubID = clubOf[team.TeamID]
+		out[i] = team
+	}
+	return out
+}
+
+func resolve(teams []model.TeamStats, aliases Aliases) ([]model.Club, map[string]string) {
+	byID := make(map[string]*model.Club)
+	order := make([]string, 0)
+	// idByName maps lower-cased club names to the club they resolved to.
+	idByName := make(map[string]string)
+	clubOf := make(map[string]string, len(teams))
+
+	add := func(id string, team model.TeamStats, club model.Club) {
+		c, ok := byID[id]
+		if !ok {
+			club.ID = id
+			club.TeamIDs = []string{}
+			c = &club
+			byID[id] = c
+			order = append(order, id)
+		}
+		if _, seen := clubOf[team.TeamID]; !seen {
+			c.TeamIDs = append(c.TeamIDs, team.TeamID)
+			clubOf[team.TeamID] = id
+		}
+		if c.LogoURL == "" && c.Source != model.ClubSourceAlias {
+			c.LogoURL = team.LogoURL
+		}
+	}
+
+	// Club IDs from fussball.de first, so that name matches can join them.
+	for _, team := range teams {
+		if team.ClubID == "" {
+			continue
+		}
+		if _, aliased := aliases.lookup(team.TeamName); aliased {
+			continue
+		}
+		name := model.ClubName(team.TeamName)
+		add(team.ClubID, team, model.Club{Name: name, Source: model.ClubSourceFussballDE})
+		if _, ok := idByName[nameKey(name)]; !ok {
+			idByName[nameKey(name)] = team.ClubID
+		}
+	}
+	for _, team := range teams {
+		if _, done := clubOf[team.TeamID]; done {
+			continue
+		}
+		name, aliased := aliases.lookup(team.TeamName)
+		source := model.ClubSourceAlias
+		if !aliased {
+			name = model.ClubName(team.TeamName)
+			source = model.ClubSourceName
+		}
+		id, ok := idByName[nameKey(name)]
+		if !ok {
+			id = slug(name)
+			idByName[nameKey(name)] = id
+		}
+		add(id, team, model.Club{Name: name, Source: source})
+	}
+
+	clubs := make([]model.Club, 0, len(order))
+	for _, id := range order {
+		clubs = append(clubs, *byID[id])
+	}
+	sort.SliceStable(clubs, func(i, j int) bool {
+		return strings.ToLower(clubs[i].Name) < strings.ToLower(clubs[j].Name)
+	})
+	return clubs, clubOf
+}
+
+// Record sums the table rows of a club's teams. Matches between two of the
+// club's teams count for both.
+type Record struct {
+	Teams        int `json:"teams"`
+	Games        int `json:"games"`
+	Wins         int `json:"wins"`
+	Draws        int `json:"draws"`
+	Losses       int `json:"losses"`
+	GoalsFor     int `json:"goalsFor"`
+	GoalsAgainst int `json:"goalsAgainst"`
+	GoalDiff     int `json:"goalDiff"`
+	Points       int `json:"points"`
+}
+
+func (r *Record) add(team model.TeamStats) {
+	r.Teams++
+	r.Games += team.Games
+	r.Wins += team.Wins
+	r.Draws += team.Draws
+	r.Losses += team.Losses
+	r.GoalsFor += team.GoalsFor
+	r.GoalsAgainst += team.GoalsAgainst
+	r.GoalDiff += team.GoalDiff
+	r.Points += team.Points
+}
+
+// Overview summarizes a club for the club list.
+type Overview struct {
+	Club   model.Club `json:"club"`
+	Teams  []string   `json:"teams"`
+	Groups []string   `json:"groups"`
+	League Record     `json:"league"`
+	Indoor Record     `json:"indoor"`
+}
+
+// Team is one of the club's teams with its groups, ratings and form.
+type Team struct {
+	TeamID        string               `json:"teamId"`
+	Name          string               `json:"name"`
+	IndoorTeamIDs []string             `json:"indoorTeamIds,omitempty"`
+	Groups        []profile.Appearance `json:"groups"`
+	Ratings       *power.TeamRatings   `json:"ratings,omitempty"`
+	Form          *model.Form          `json:"form,omitempty"`
+}
+
+// Dashboard gathers all teams and matches of a club.
+type Dashboard struct {
+	Club    model.Club          `json:"club"`
+	Teams   []Team              `json:"teams"`
+	League  Record              `json:"league"`
+	Indoor  Record              `json:"indoor"`
+	Matches []model.MatchResult `json:"matches"`
+}
+
+// Input holds the data clubs are summarized from. Ratings are keyed by league
+// team ID, see power.RateAll.
+type Input struct {
+	League  []model.GroupSnapshot
+	Indoor  []model.GroupSnapshot
+	Aliases Aliases
+	Ratings map[string]power.TeamRatings
+	Form    power.FormOptions
+}
+
+// List summarizes every club, ordered by name.
+func List(in Input) []Overview {
+	clubs, clubOf := resolve(allTeams(in), in.Aliases)
+	overviews := make([]Overview, len(clubs))
+	index := make(map[string]int, len(clubs))
+	for i, c := range clubs {
+		overviews[i] = Overview{Club: c, Teams: []string{}, Groups: []string{}}
+		index[c.ID] = i
+	}
+
+	mapping := indoorMapping(in)
+	seenTeam := make(map[string]bool)
+	seenGroup := make(map[string]bool)
+	tally := func(snaps []model.GroupSnapshot, indoor bool) {
+		for _, snap := range snaps {
+			for _, team := range snap.Teams {
+				o := &overviews[index[clubOf[team.TeamID]]]
+				if indoor {
+					o.Indoor.add(team)
+				} else {
+					o.League.add(team)
+				}
+				key := o.Club.ID + "|" + teamKey(team.TeamID, mapping)
+				if !seenTeam[key] {
+					seenTeam[key] = true
+					o.Teams = append(o.Teams, team.TeamName)
+				}
+				key = o.Club.ID + "|" + team.GroupName
+				if !seenGroup[key] {
+					seenGroup[key] = true
+					o.Groups = append(o.Groups, team.GroupName)
+				}
+			}
+		}
+	}
+	tally(in.League, false)
+	tally(in.Indoor, true)
+	return overviews
+}
+
+// Build assembles the dashboard of the club with the given ID.
+func Build(in Input, clubID string) (Dashboard, bool) {
+	clubs, _ := resolve(allTeams(in), in.Aliases)
+	var c model.Club
+	for _, candidate := range clubs {
+		if candidate.ID == clubID {
+			c = candidate
+			break
+		}
+	}
+	if c.ID == "" {
+		return Dashboard{}, false
+	}
+
+	d := Dashboard{Club: c, Teams: []Team{}, Matches: []model.MatchResult{}}
+	members := make(map[string]bool, len(c.TeamIDs))
+	for _, id := range c.TeamIDs {
+		members[id] = true
+	}
+	for _, snap := range in.League {
+		for _, team := range snap.Teams {
+			if members[team.TeamID] {
+				d.League.add(team)
+			}
+		}
+	}
+	for _, snap := range in.Indoor {
+		for _, team := range snap.Teams {
+			if members[team.TeamID] {
+				d.Indoor.add(team)
+			}
+		}
+	}
+
+	profileIn := profile.Input{League: in.League, Indoor: in.Indoor, Ratings: in.Ratings, Form: in.Form}
+	seenTeam := make(map[string]bool)
+	seenMatch := make(map[string]bool)
+	for _, id := range c.TeamIDs {
+		p, ok := profile.Build(profileIn, id)
+		if !ok || seenTeam[p.TeamID] {
+			continue
+		}
+		seenTeam[p.TeamID] = true
+		d.Teams = append(d.Teams, Team{
+			TeamID:        p.TeamID,
+			Name:          p.Name,
+			IndoorTeamIDs: p.IndoorTeamIDs,
+			Groups:        p.Groups,
+			Ratings:       p.Ratings,
+			Form:          p.Form,
+		})
+		for _, m := range p.Matches {
+			// Derbies show up in both teams' matches.
+			key := m.GroupID + "|" + m.ID
+			if m.ID != "" && seenMatch[key] {
+				continue
+			}
+			seenMatch[key] = true
+			d.Matches = append(d.Matches, m)
+		}
+	}
+	model.SortChronologically(d.Matches)
+	return d, true
+}
+
+func allTeams(in Input) []model.TeamStats {
+	var teams []model.TeamStats
+	for _, snap := range in.League {
+		teams = append(teams, snap.Teams...)
+	}
+	for _, snap := range in.Indoor {
+		teams = append(teams, snap.Teams...)
+	}
+	return teams
+}
+
+// indoorMapping maps indoor team IDs to the matching league team IDs.
+func indoorMapping(in Input) map[string]string {
+	var league, indoor []model.TeamStats
+	for _, snap := range in.League {
+		league = append(league, snap.Teams...)
+	}
+	for _, snap := range in.Indoor {
+		indoor = append(indoor, snap.Teams...)
+	}
+	mapping, _, _ := power.MatchIndoorTeams(league, indoor)
+	return mapping
+}
+
+func teamKey(teamID string, mapping map[string]string) string {
+	if id, ok := mapping[teamID]; ok {
+		return id
+	}
+	return teamID
+}
+
+func nameKey(name string) string {
+	name = strings.ReplaceAll(name, "\u200b", "")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+var transliterations = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss")
+
+// slug turns a club name into a URL-safe ID, e.g. "TSV Nieste" -> "tsv-nieste".
+func slug(name string) string {
+	name = transliterations.Replace(nameKey(name))
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
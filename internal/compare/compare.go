@@ -0,0 +1,243 @@
+package compare
+
+import (
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// DefaultMaxLinks is the longest opponent chain Compare follows.
+const DefaultMaxLinks = 3
+
+// maxChains bounds the number of chains returned; the shortest and best
+// supported chains are kept.
+const maxChains = 50
+
+// CommonOpponent lists both teams' results against a shared opponent.
+type CommonOpponent struct {
+	OpponentID string              `json:"opponentId"`
+	Opponent   string              `json:"opponent"`
+	MatchesA   []model.MatchResult `json:"matchesA"`
+	MatchesB   []model.MatchResult `json:"matchesB"`
+	// GoalDiffA and GoalDiffB are the average goal differences against the
+	// opponent from each team's point of view.
+	GoalDiffA float64 `json:"goalDiffA"`
+	GoalDiffB float64 `json:"goalDiffB"`
+	// Implied is the goal difference of A against B this opponent suggests.
+	Implied float64 `json:"implied"`
+}
+
+// Link is one step of a chain: the average goal difference of From against To.
+type Link struct {
+	FromID   string  `json:"fromId"`
+	From     string  `json:"from"`
+	ToID     string  `json:"toId"`
+	To       string  `json:"to"`
+	GoalDiff float64 `json:"goalDiff"`
+	Matches  int     `json:"matches"`
+}
+
+// Chain connects A to B through played matches. GoalDiff is the sum of the
+// link goal differences, i.e. the transitive margin of A over B.
+type Chain struct {
+	Links    []Link  `json:"links"`
+	GoalDiff float64 `json:"goalDiff"`
+}
+
+// Summary averages the evidence of each kind from A's point of view.
+type Summary struct {
+	DirectMatches   int     `json:"directMatches"`
+	DirectGoalDiff  float64 `json:"directGoalDiff"`
+	CommonOpponents int     `json:"commonOpponents"`
+	CommonGoalDiff  float64 `json:"commonGoalDiff"`
+	Chains          int     `json:"chains"`
+	ChainGoalDiff   float64 `json:"chainGoalDiff"`
+}
+
+// Comparison is the evidence for how team A compares with team B.
+type Comparison struct {
+	TeamA           string              `json:"teamA"`
+	TeamB           string              `json:"teamB"`
+	DirectMatches   []model.MatchResult `json:"directMatches"`
+	CommonOpponents []CommonOpponent    `json:"commonOpponents"`
+	Chains          []Chain             `json:"chains"`
+	Summary         Summary             `json:"summary"`
+}
+
+type edge struct {
+	goalDiff float64
+	matches  int
+}
+
+// Compare collects direct matches, common opponents and opponent chains of two
+// to maxLinks links between teams a and b.
+func Compare(matches []model.MatchResult, a, b string, maxLinks int) Comparison {
+	if maxLinks <= 0 {
+		maxLinks = DefaultMaxLinks
+	}
+
+	c := Comparison{
+		TeamA:           a,
+		TeamB:           b,
+		DirectMatches:   []model.MatchResult{},
+		CommonOpponents: []CommonOpponent{},
+		Chains:          []Chain{},
+	}
+
+	// graph[x][y] holds the total goal difference of x against y.
+	graph := make(map[string]map[string]edge)
+	names := make(map[string]string)
+	byTeam := make(map[string][]model.MatchResult)
+	addEdge := func(from, to string, goalDiff int) {
+		if graph[from] == nil {
+			graph[from] = make(map[string]edge)
+		}
+		e := graph[from][to]
+		e.goalDiff += float64(goalDiff)
+		e.matches++
+		graph[from][to] = e
+	}
+	for _, m := range matches {
+		if !m.Played() || m.HomeTeamID == "" || m.AwayTeamID == "" || m.HomeTeamID == m.AwayTeamID {
+			continue
+		}
+		// Keep the first name seen, so callers can put league matches first.
+		if _, ok := names[m.HomeTeamID]; !ok {
+			names[m.HomeTeamID] = m.HomeTeam
+		}
+		if _, ok := names[m.AwayTeamID]; !ok {
+			names[m.AwayTeamID] = m.AwayTeam
+		}
+		addEdge(m.HomeTeamID, m.AwayTeamID, m.HomeScore-m.AwayScore)
+		addEdge(m.AwayTeamID, m.HomeTeamID, m.AwayScore-m.HomeScore)
+		byTeam[m.HomeTeamID] = append(byTeam[m.HomeTeamID], m)
+		byTeam[m.AwayTeamID] = append(byTeam[m.AwayTeamID], m)
+
+		if (m.HomeTeamID == a && m.AwayTeamID == b) || (m.HomeTeamID == b && m.AwayTeamID == a) {
+			c.DirectMatches = append(c.DirectMatches, m)
+		}
+	}
+	model.SortChronologically(c.DirectMatches)
+
+	if e, ok := graph[a][b]; ok {
+		c.Summary.DirectMatches = e.matches
+		c.Summary.DirectGoalDiff = e.goalDiff / float64(e.matches)
+	}
+
+	for opponent, ea := range graph[a] {
+		eb, ok := graph[b][opponent]
+		if !ok || opponent == b {
+			continue
+		}
+		common := CommonOpponent{
+			OpponentID: opponent,
+			Opponent:   names[opponent],
+			MatchesA:   matchesAgainst(byTeam[a], opponent),
+			MatchesB:   matchesAgainst(byTeam[b], opponent),
+			GoalDiffA:  ea.goalDiff / float64(ea.matches),
+			GoalDiffB:  eb.goalDiff / float64(eb.matches),
+		}
+		common.Implied = common.GoalDiffA - common.GoalDiffB
+		c.CommonOpponents = append(c.CommonOpponents, common)
+		c.Summary.CommonGoalDiff += common.Implied
+	}
+	sort.Slice(c.CommonOpponents, func(i, j int) bool {
+		return c.CommonOpponents[i].Opponent < c.CommonOpponents[j].Opponent
+	})
+	if c.Summary.CommonOpponents = len(c.CommonOpponents); c.Summary.CommonOpponents > 0 {
+		c.Summary.CommonGoalDiff /= float64(c.Summary.CommonOpponents)
+	}
+
+	visited := map[string]bool{a: true}
+	var path []string
+	var walk func(at string)
+	walk = func(at string) {
+		if at == b {
+			// A single link is a direct match, which is reported separately.
+			if len(path) > 1 {
+				c.Chains = append(c.Chains, buildChain(a, path, graph, names))
+			}
+			return
+		}
+		if len(path) == maxLinks {
+			return
+		}
+		next := make([]string, 0, len(graph[at]))
+		for id := range graph[at] {
+			next = append(next, id)
+		}
+		sort.Strings(next)
+		for _, id := range next {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			path = append(path, id)
+			walk(id)
+			path = path[:len(path)-1]
+			visited[id] = false
+		}
+	}
+	walk(a)
+
+	sort.SliceStable(c.Chains, func(i, j int) bool {
+		if len(c.Chains[i].Links) != len(c.Chains[j].Links) {
+			return len(c.Chains[i].Links) < len(c.Chains[j].Links)
+		}
+		return chainMatches(c.Chains[i]) > chainMatches(c.Chains[j])
+	})
+	c.Summary.Chains = len(c.Chains)
+	for _, chain := range c.Chains {
+		c.Summary.ChainGoalDiff += chain.GoalDiff
+	}
+	if c.Summary.Chains > 0 {
+		c.Summary.ChainGoalDiff /= float64(c.Summary.Chains)
+	}
+	if len(c.Chains) > maxChains {
+		c.Chains = c.Chains[:maxChains]
+	}
+
+	return c
+}
+
+func buildChain(start string, path []string, graph map[string]map[string]edge, names map[string]string) Chain {
+	chain := Chain{Links: make([]Link, 0, len(path))}
+	from := start
+	for _, to := range path {
+		e := graph[from][to]
+		link := Link{
+			FromID:   from,
+			From:     names[from],
+			ToID:     to,
+			To:       names[to],
+			GoalDiff: e.goalDiff / float64(e.matches),
+			Matches:  e.matches,
+		}
+		chain.Links = append(chain.Links, link)
+		chain.GoalDiff += link.GoalDiff
+		from = to
+	}
+	return chain
+}
+
+// chainMatches returns the number of matches behind the weakest link.
+func chainMatches(chain Chain) int {
+	weakest := 0
+	for i, link := range chain.Links {
+		if i == 0 || link.Matches < weakest {
+			weakest = link.Matches
+		}
+	}
+	return weakest
+}
+
+func matchesAgainst(matches []model.MatchResult, opponent string) []model.MatchResult {
+	out := make([]model.MatchResult, 0)
+	for _, m := range matches {
+		if m.HomeTeamID == opponent || m.AwayTeamID == opponent {
+			out = append(out, m)
+		}
+	}
+	model.SortChronologically(out)
+	return out
+}
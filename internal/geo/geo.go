@@ -0,0 +1,137 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// Location is where a club or team plays its home games. Venues lists the
+// venue names seen in fixtures, to help filling in the coordinates.
+type Location struct {
+	Name   string   `json:"name,omitempty"`
+	Lat    float64  `json:"lat"`
+	Lon    float64  `json:"lon"`
+	Venues []string `json:"venues,omitempty"`
+}
+
+// Known reports whether the location has coordinates.
+func (l Location) Known() bool {
+	return l.Lat != 0 || l.Lon != 0
+}
+
+// Locations maps a club ID, team ID, team name or club name to its location.
+// Name keys match case-insensitively.
+type Locations map[string]Location
+
+// LoadLocations reads locations from a JSON object of key to location. No
+// geocoding happens: coordinates come from the file only.
+func LoadLocations(path string) (Locations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]Location
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse locations %s: %w", path, err)
+	}
+	locations := make(Locations, len(raw))
+	for key, loc := range raw {
+		if loc.Lat < -90 || loc.Lat > 90 || loc.Lon < -180 || loc.Lon > 180 {
+			return nil, fmt.Errorf("locations %s: %q has invalid coordinates", path, key)
+		}
+		locations[nameKey(key)] = loc
+	}
+	return locations, nil
+}
+
+// Locate returns the known location of every team it can place, keyed by team
+// ID. Teams are looked up by ID, club ID, name and club name, in that order.
+func (l Locations) Locate(teams []model.TeamStats) map[string]Location {
+	out := make(map[string]Location)
+	if len(l) == 0 {
+		return out
+	}
+	for _, team := range teams {
+		for _, key := range []string{team.TeamID, team.ClubID, team.TeamName, model.ClubName(team.TeamName)} {
+			if loc, ok := l[nameKey(key)]; ok && key != "" && loc.Known() {
+				out[team.TeamID] = loc
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Seed builds a locations file to fill in: one entry per club, keyed by club
+// ID, with the venues of the club's home matches, most frequent first.
+// Coordinates are taken over from existing where the club has them. Teams
+// should have their ClubID assigned.
+func Seed(teams []model.TeamStats, matches []model.MatchResult, existing Locations) Locations {
+	clubOf := make(map[string]string, len(teams))
+	seed := make(Locations)
+	for _, team := range teams {
+		key := team.ClubID
+		if key == "" {
+			key = model.ClubName(team.TeamName)
+		}
+		clubOf[team.TeamID] = key
+		if _, ok := seed[key]; !ok {
+			seed[key] = Location{Name: model.ClubName(team.TeamName)}
+		}
+	}
+
+	venues := make(map[string]map[string]int)
+	for _, m := range matches {
+		key, ok := clubOf[m.HomeTeamID]
+		venue := strings.TrimSpace(m.Venue)
+		if !ok || venue == "" || m.Neutral {
+			continue
+		}
+		if venues[key] == nil {
+			venues[key] = make(map[string]int)
+		}
+		venues[key][venue]++
+	}
+
+	for key, loc := range seed {
+		counts := venues[key]
+		for venue := range counts {
+			loc.Venues = append(loc.Venues, venue)
+		}
+		sort.Slice(loc.Venues, func(i, j int) bool {
+			if counts[loc.Venues[i]] != counts[loc.Venues[j]] {
+				return counts[loc.Venues[i]] > counts[loc.Venues[j]]
+			}
+			return loc.Venues[i] < loc.Venues[j]
+		})
+		if known, ok := existing[nameKey(key)]; ok && known.Known() {
+			loc.Lat, loc.Lon = known.Lat, known.Lon
+		} else if known, ok := existing[nameKey(loc.Name)]; ok && known.Known() {
+			loc.Lat, loc.Lon = known.Lat, known.Lon
+		}
+		seed[key] = loc
+	}
+	return seed
+}
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between two locations.
+func DistanceKm(a, b Location) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func nameKey(name string) string {
+	name = strings.ReplaceAll(name, "\u200b", "")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
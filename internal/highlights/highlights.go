@@ -0,0 +1,307 @@
+package highlights
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/history"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// Priors that set the expected result of a match.
+const (
+	PriorElo   = "elo"
+	PriorModel = "model"
+)
+
+// Highlight kinds.
+const (
+	KindUpset  = "upset"
+	KindMargin = "margin"
+)
+
+// Options configures Build.
+type Options struct {
+	// Prior is PriorElo or PriorModel. The goal model always provides the
+	// expected margin.
+	Prior string
+	Elo   power.EloParams
+	Model power.GoalModelOptions
+	// MinPriorGames is the number of earlier games both teams need before
+	// their result can be a surprise.
+	MinPriorGames int
+	// UpsetExpected flags wins of teams whose expected score was at most this.
+	UpsetExpected float64
+	// MarginZ flags results whose goal difference is this many standard
+	// deviations away from the expected one.
+	MarginZ float64
+	// RefitEvery refits the goal model after this many matches even when the
+	// date and matchday do not change, e.g. for undated matches.
+	RefitEvery int
+}
+
+// DefaultOptions returns the options used by /api/highlights.
+func DefaultOptions() Options {
+	return Options{
+		Prior:         PriorElo,
+		Elo:           power.DefaultEloParams(),
+		Model:         power.DefaultGoalModelOptions(),
+		MinPriorGames: 2,
+		UpsetExpected: 0.35,
+		MarginZ:       2,
+		RefitEvery:    10,
+	}
+}
+
+// ParsePrior validates a prior name; empty selects Elo.
+func ParsePrior(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", PriorElo:
+		return PriorElo, nil
+	case PriorModel:
+		return PriorModel, nil
+	}
+	return "", fmt.Errorf("prior must be %s or %s", PriorElo, PriorModel)
+}
+
+// Highlight is a result that beat expectations. Expectations come from the
+// matches before it only.
+type Highlight struct {
+	Match  model.MatchResult `json:"match"`
+	Period string            `json:"period"`
+	Kinds  []string          `json:"kinds"`
+	// ExpectedScore is the home team's expected score, 1 for a sure win.
+	ExpectedScore float64 `json:"expectedScore"`
+	ExpectedDiff  float64 `json:"expectedDiff"`
+	ActualDiff    int     `json:"actualDiff"`
+	// Favorite is the team that was expected to do better.
+	FavoriteID string `json:"favoriteId"`
+	Favorite   string `json:"favorite"`
+	// Surprise is the larger of the outcome and the margin residual, each in
+	// standard deviations, so both kinds sort on one scale.
+	Surprise float64 `json:"surprise"`
+}
+
+// Streak is a run of games without defeat.
+type Streak struct {
+	TeamID string `json:"teamId"`
+	Team   string `json:"team"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Draws  int    `json:"draws"`
+	Since  string `json:"since,omitempty"`
+}
+
+// Week summarizes one period of matches.
+type Week struct {
+	Period  string `json:"period"`
+	Matches int    `json:"matches"`
+	Goals   int    `json:"goals"`
+	// BiggestUpset is the win of the least expected winner, if an underdog won.
+	BiggestUpset   *Highlight         `json:"biggestUpset,omitempty"`
+	HighestScoring *model.MatchResult `json:"highestScoring,omitempty"`
+	// LongestUnbeaten is the longest running unbeaten streak after the week.
+	LongestUnbeaten *Streak `json:"longestUnbeaten,omitempty"`
+}
+
+// Feed is the output of Build.
+type Feed struct {
+	Prior      string      `json:"prior"`
+	Highlights []Highlight `json:"highlights"`
+	// Weeks lists the weekly summaries, newest first. It is empty when the
+	// matches have no periods, see history.Periods.
+	Weeks []Week `json:"weeks"`
+}
+
+// Build replays the played matches in order, predicts each from the matches
+// before it and returns the flagged results ordered by surprise together with
+// weekly summaries.
+func Build(matches []model.MatchResult, opts Options) Feed {
+	played := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if m.Played() && m.HomeTeamID != "" && m.AwayTeamID != "" && m.HomeTeamID != m.AwayTeamID {
+			played = append(played, m)
+		}
+	}
+	model.SortChronologically(played)
+	periodOf := history.Periods(played)
+
+	feed := Feed{Prior: opts.Prior, Highlights: []Highlight{}, Weeks: []Week{}}
+	rater := power.NewEloRater(opts.Elo)
+	var goalModel power.GoalModel
+	games := make(map[string]int)
+	streaks := make(map[string]*Streak)
+	weeks := make(map[string]*Week)
+	var order []string
+	var all []Highlight
+
+	fitted := 0
+	for i, m := range played {
+		if newMatchday(played, i) || (opts.RefitEvery > 0 && i-fitted >= opts.RefitEvery) {
+			goalModel = power.FitGoalModel(played[:i], opts.Model)
+			fitted = i
+		}
+
+		h := Highlight{
+			Match:         m,
+			Period:        periodOf(m),
+			ExpectedScore: rater.Expected(m),
+			ExpectedDiff:  goalModel.Predict(m),
+			ActualDiff:    m.HomeScore - m.AwayScore,
+		}
+		if opts.Prior == PriorModel {
+			h.ExpectedScore = expectedScore(h.ExpectedDiff, goalModel.Sigma)
+		}
+		h.FavoriteID, h.Favorite = m.HomeTeamID, m.HomeTeam
+		if h.ExpectedScore < 0.5 {
+			h.FavoriteID, h.Favorite = m.AwayTeamID, m.AwayTeam
+		}
+
+		eligible := games[m.HomeTeamID] >= opts.MinPriorGames && games[m.AwayTeamID] >= opts.MinPriorGames
+		if eligible {
+			flag(&h, goalModel.Sigma, opts)
+			if len(h.Kinds) > 0 {
+				all = append(all, h)
+			}
+		}
+		rater.Apply(m)
+		games[m.HomeTeamID]++
+		games[m.AwayTeamID]++
+
+		updateStreak(streaks, m.HomeTeamID, m.HomeTeam, m.HomeScore-m.AwayScore, m)
+		updateStreak(streaks, m.AwayTeamID, m.AwayTeam, m.AwayScore-m.HomeScore, m)
+		if h.Period == "" {
+			continue
+		}
+		week, ok := weeks[h.Period]
+		if !ok {
+			week = &Week{Period: h.Period}
+			weeks[h.Period] = week
+			order = append(order, h.Period)
+		}
+		week.Matches++
+		week.Goals += m.HomeScore + m.AwayScore
+		if week.HighestScoring == nil || m.HomeScore+m.AwayScore > week.HighestScoring.HomeScore+week.HighestScoring.AwayScore {
+			match := m
+			week.HighestScoring = &match
+		}
+		if expected, ok := winnerExpected(&h); eligible && ok && expected < 0.5 {
+			if best, _ := winnerExpected(week.BiggestUpset); week.BiggestUpset == nil || expected < best {
+				upset := h
+				week.BiggestUpset = &upset
+			}
+		}
+		if i == len(played)-1 || periodOf(played[i+1]) != h.Period {
+			week.LongestUnbeaten = longestStreak(streaks)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Surprise > all[j].Surprise
+	})
+	feed.Highlights = append(feed.Highlights, all...)
+	for i := len(order) - 1; i >= 0; i-- {
+		feed.Weeks = append(feed.Weeks, *weeks[order[i]])
+	}
+	return feed
+}
+
+// flag marks upsets and unusual margins and sets the surprise.
+func flag(h *Highlight, sigma float64, opts Options) {
+	actual := 0.5
+	switch {
+	case h.ActualDiff > 0:
+		actual = 1
+	case h.ActualDiff < 0:
+		actual = 0
+	}
+	p := h.ExpectedScore
+	if p > 0 && p < 1 {
+		h.Surprise = math.Abs(actual-p) / math.Sqrt(p*(1-p))
+	}
+	if expected, ok := winnerExpected(h); ok && expected <= opts.UpsetExpected {
+		h.Kinds = append(h.Kinds, KindUpset)
+	}
+
+	// Only margins larger than expected count; a narrow win of the favourite
+	// is not notable.
+	if sigma > 0 && math.Abs(float64(h.ActualDiff)) > math.Abs(h.ExpectedDiff) {
+		z := math.Abs(float64(h.ActualDiff)-h.ExpectedDiff) / sigma
+		if z >= opts.MarginZ {
+			h.Kinds = append(h.Kinds, KindMargin)
+		}
+		h.Surprise = math.Max(h.Surprise, z)
+	}
+}
+
+// winnerExpected returns the expected score of the team that won, or false
+// for a draw.
+func winnerExpected(h *Highlight) (float64, bool) {
+	switch {
+	case h == nil || h.ActualDiff == 0:
+		return 0, false
+	case h.ActualDiff > 0:
+		return h.ExpectedScore, true
+	}
+	return 1 - h.ExpectedScore, true
+}
+
+// expectedScore turns an expected goal difference into the home team's
+// expected score, treating the goal difference as normally distributed.
+func expectedScore(diff, sigma float64) float64 {
+	if sigma <= 0 {
+		return 0.5
+	}
+	return 0.5 * (1 + math.Erf(diff/sigma/math.Sqrt2))
+}
+
+// updateStreak extends or resets a team's unbeaten run after a match with
+// the given goal difference from the team's point of view.
+func updateStreak(streaks map[string]*Streak, teamID, name string, goalDiff int, m model.MatchResult) {
+	s, ok := streaks[teamID]
+	if !ok {
+		s = &Streak{TeamID: teamID, Team: name}
+		streaks[teamID] = s
+	}
+	if goalDiff < 0 {
+		*s = Streak{TeamID: teamID, Team: s.Team}
+		return
+	}
+	if s.Games == 0 {
+		s.Since = m.MatchDate
+	}
+	s.Games++
+	if goalDiff > 0 {
+		s.Wins++
+	} else {
+		s.Draws++
+	}
+}
+
+func longestStreak(streaks map[string]*Streak) *Streak {
+	var best *Streak
+	for _, s := range streaks {
+		if s.Games == 0 {
+			continue
+		}
+		if best == nil || s.Games > best.Games ||
+			(s.Games == best.Games && s.Wins > best.Wins) ||
+			(s.Games == best.Games && s.Wins == best.Wins && s.Team < best.Team) {
+			streak := *s
+			best = &streak
+		}
+	}
+	return best
+}
+
+// newMatchday reports whether matches[i] starts a new date or matchday, i.e.
+// when the goal model needs refitting.
+func newMatchday(matches []model.MatchResult, i int) bool {
+	if i == 0 {
+		return true
+	}
+	return matches[i-1].MatchDate != matches[i].MatchDate || matches[i-1].MatchdayTag != matches[i].MatchdayTag
+}
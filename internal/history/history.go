@@ -0,0 +1,215 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// Entry is a team's standing after one period of matches.
+type Entry struct {
+	// Period is the ISO week ("2025-W36") or, when no match has a date, the
+	// matchday, see Periods.
+	Period     string  `json:"period"`
+	Date       string  `json:"date,omitempty"`
+	GroupID    string  `json:"groupId"`
+	Games      int     `json:"games"`
+	Points     int     `json:"points"`
+	GoalDiff   int     `json:"goalDiff"`
+	GroupRank  int     `json:"groupRank"`
+	PowerScore float64 `json:"powerScore"`
+	PowerRank  int     `json:"powerRank"`
+}
+
+// Build replays the matches period by period and records, after every period,
+// each team's table rank within its group and its overall power rank. Teams
+// are keyed by TeamID; a team listed in several groups gets one entry per
+// group and period.
+func Build(teams []model.TeamStats, matches []model.MatchResult, opts power.MetricOptions) map[string][]Entry {
+	played := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if m.Played() && m.HomeTeamID != "" && m.AwayTeamID != "" {
+			played = append(played, m)
+		}
+	}
+	model.SortChronologically(played)
+	periodOf := Periods(played)
+	sort.SliceStable(played, func(i, j int) bool {
+		return periodOf(played[i]) < periodOf(played[j])
+	})
+
+	// Start from an empty table so teams without matches yet show zeros
+	// instead of their current table stats.
+	base := zeroStats(teams)
+	byGroup := make(map[string][]model.TeamStats)
+	for _, team := range base {
+		byGroup[team.GroupID] = append(byGroup[team.GroupID], team)
+	}
+
+	out := make(map[string][]Entry)
+	for end := 0; end < len(played); {
+		period := periodOf(played[end])
+		date := ""
+		for end < len(played) && periodOf(played[end]) == period {
+			if played[end].MatchDate > date {
+				date = played[end].MatchDate
+			}
+			end++
+		}
+		// Matches without a period count from the start but get no entry.
+		if period == "" {
+			continue
+		}
+		prior := played[:end]
+
+		// The group table only counts the group's own matches.
+		groupMatches := make(map[string][]model.MatchResult)
+		for _, m := range prior {
+			groupMatches[m.GroupID] = append(groupMatches[m.GroupID], m)
+		}
+		groupStats := make(map[string]map[string]model.TeamStats, len(byGroup))
+		groupRanks := make(map[string]map[string]int, len(byGroup))
+		for groupID, groupTeams := range byGroup {
+			table := model.ApplyMatchAggregates(groupTeams, groupMatches[groupID])
+			groupStats[groupID] = make(map[string]model.TeamStats, len(table))
+			for _, team := range table {
+				groupStats[groupID][team.TeamID] = team
+			}
+			groupRanks[groupID] = tableRanks(table)
+		}
+
+		overall := model.ApplyMatchAggregates(base, prior)
+		metrics := power.ComputeMetricsWithOptions(overall, prior, opts)
+		powerRanks := scoreRanks(overall, metrics)
+
+		for _, team := range base {
+			groupTeam := groupStats[team.GroupID][team.TeamID]
+			out[team.TeamID] = append(out[team.TeamID], Entry{
+				Period:     period,
+				Date:       date,
+				GroupID:    team.GroupID,
+				Games:      groupTeam.Games,
+				Points:     3*groupTeam.Wins + groupTeam.Draws,
+				GoalDiff:   groupTeam.GoalDiff,
+				GroupRank:  groupRanks[team.GroupID][team.TeamID],
+				PowerScore: metrics[team.TeamID].PowerScore,
+				PowerRank:  powerRanks[team.TeamID],
+			})
+		}
+	}
+	return out
+}
+
+// PeriodFunc returns the period of a match, or "" when it has none.
+type PeriodFunc func(model.MatchResult) string
+
+const matchdayPrefix = "matchday "
+
+// Periods picks one period scheme for matches, so their keys sort in play
+// order: ISO weeks ("2025-W36") when any played match has a date, matchdays
+// ("matchday 07") otherwise. Matches the scheme cannot place get "".
+func Periods(matches []model.MatchResult) PeriodFunc {
+	for _, m := range matches {
+		if m.Played() && weekKey(m) != "" {
+			return weekKey
+		}
+	}
+	return matchdayKey
+}
+
+// periodScheme returns the scheme a period key of Periods belongs to.
+func periodScheme(period string) PeriodFunc {
+	if strings.HasPrefix(period, matchdayPrefix) {
+		return matchdayKey
+	}
+	return weekKey
+}
+
+func weekKey(m model.MatchResult) string {
+	d, err := time.Parse("2006-01-02", m.MatchDate)
+	if err != nil {
+		return ""
+	}
+	year, week := d.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+func matchdayKey(m model.MatchResult) string {
+	n, err := strconv.Atoi(m.MatchdayTag)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s%02d", matchdayPrefix, n)
+}
+
+func zeroStats(teams []model.TeamStats) []model.TeamStats {
+	out := make([]model.TeamStats, len(teams))
+	for i, team := range teams {
+		out[i] = model.TeamStats{
+			GroupID:   team.GroupID,
+			GroupName: team.GroupName,
+			StaffelID: team.StaffelID,
+			TeamID:    team.TeamID,
+			TeamName:  team.TeamName,
+			LogoURL:   team.LogoURL,
+			ClubID:    team.ClubID,
+		}
+	}
+	return out
+}
+
+// tableRanks ranks teams like the league table: points, goal difference,
+// goals scored.
+func tableRanks(teams []model.TeamStats) map[string]int {
+	sorted := append([]model.TeamStats(nil), teams...)
+	for i := range sorted {
+		sorted[i].Points = 3*sorted[i].Wins + sorted[i].Draws
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Points != sorted[j].Points {
+			return sorted[i].Points > sorted[j].Points
+		}
+		if sorted[i].GoalDiff != sorted[j].GoalDiff {
+			return sorted[i].GoalDiff > sorted[j].GoalDiff
+		}
+		if sorted[i].GoalsFor != sorted[j].GoalsFor {
+			return sorted[i].GoalsFor > sorted[j].GoalsFor
+		}
+		return sorted[i].TeamName < sorted[j].TeamName
+	})
+	ranks := make(map[string]int, len(sorted))
+	for i, team := range sorted {
+		ranks[team.TeamID] = i + 1
+	}
+	return ranks
+}
+
+// scoreRanks ranks teams by power score; teams without games rank last.
+func scoreRanks(teams []model.TeamStats, metrics map[string]model.MetricSet) map[string]int {
+	sorted := append([]model.TeamStats(nil), teams...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Games > 0) != (sorted[j].Games > 0) {
+			return sorted[i].Games > 0
+		}
+		pi, pj := metrics[sorted[i].TeamID].PowerScore, metrics[sorted[j].TeamID].PowerScore
+		if pi != pj {
+			return pi > pj
+		}
+		if sorted[i].GoalDiff != sorted[j].GoalDiff {
+			return sorted[i].GoalDiff > sorted[j].GoalDiff
+		}
+		return sorted[i].TeamName < sorted[j].TeamName
+	})
+	ranks := make(map[string]int, len(sorted))
+	for i, team := range sorted {
+		if _, ok := ranks[team.TeamID]; !ok {
+			ranks[team.TeamID] = i + 1
+		}
+	}
+	return ranks
+}
@@ -0,0 +1,213 @@
+package history
+
+import (
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// Standing is a team's place in one ranking at one point in time.
+type Standing struct {
+	Rank   int
+	Score  float64
+	Points int
+}
+
+// Mover is a team with its movement, see Movers.
+type Mover struct {
+	Team     model.TeamStats `json:"team"`
+	Movement model.Movement  `json:"movement"`
+}
+
+// LatestPeriod returns the period of the most recent played match, or "" when
+// no played match has one. See Periods.
+func LatestPeriod(matches []model.MatchResult) string {
+	periodOf := Periods(matches)
+	latest := ""
+	for _, m := range matches {
+		if m.Played() {
+			if period := periodOf(m); period > latest {
+				latest = period
+			}
+		}
+	}
+	return latest
+}
+
+// PreviousPeriod returns the latest period with a played match before period.
+func PreviousPeriod(matches []model.MatchResult, period string) string {
+	periodOf := periodScheme(period)
+	previous := ""
+	for _, m := range matches {
+		if !m.Played() {
+			continue
+		}
+		if key := periodOf(m); key < period && key > previous {
+			previous = key
+		}
+	}
+	return previous
+}
+
+// Replay rebuilds the table from the played matches before period, or from
+// all played matches when period is empty. Matches without a period count as
+// played before it. Teams keep their identity but their stats come from the
+// replayed matches only.
+func Replay(teams []model.TeamStats, matches []model.MatchResult, period string) ([]model.TeamStats, []model.MatchResult) {
+	periodOf := periodScheme(period)
+	kept := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if m.Played() && (period == "" || periodOf(m) < period) {
+			kept = append(kept, m)
+		}
+	}
+	replayed := model.ApplyMatchAggregates(zeroStats(teams), kept)
+	for i := range replayed {
+		replayed[i].Points = 3*replayed[i].Wins + replayed[i].Draws
+	}
+	return replayed, kept
+}
+
+// Movements compares the standings after the latest period with those before
+// it. Teams missing from before keep their current rank.
+func Movements(now, before map[string]Standing, period, previousPeriod string) map[string]model.Movement {
+	out := make(map[string]model.Movement, len(now))
+	for teamID, current := range now {
+		previous, ok := before[teamID]
+		if !ok {
+			previous = current
+		}
+		movement := model.Movement{
+			Period:         period,
+			PreviousPeriod: previousPeriod,
+			Rank:           current.Rank,
+			PreviousRank:   previous.Rank,
+			RankChange:     previous.Rank - current.Rank,
+			ScoreDelta:     current.Score - previous.Score,
+			PointsDelta:    current.Points - previous.Points,
+			Trend:          "same",
+		}
+		switch {
+		case movement.RankChange > 0:
+			movement.Trend = "up"
+		case movement.RankChange < 0:
+			movement.Trend = "down"
+		}
+		out[teamID] = movement
+	}
+	return out
+}
+
+// TableMovements returns the movement of each team in its group table since
+// the group's previous period, so groups that did not play in the latest
+// week still show their last change. Groups without periods have none.
+// Tables are ranked like the league table, count only the group's own matches
+// and are scored by goal difference.
+func TableMovements(teams []model.TeamStats, matches []model.MatchResult) map[string]model.Movement {
+	groupTeams := make(map[string][]model.TeamStats)
+	for _, team := range teams {
+		groupTeams[team.GroupID] = append(groupTeams[team.GroupID], team)
+	}
+	groupMatches := make(map[string][]model.MatchResult)
+	for _, m := range matches {
+		groupMatches[m.GroupID] = append(groupMatches[m.GroupID], m)
+	}
+
+	out := make(map[string]model.Movement, len(teams))
+	for groupID, teams := range groupTeams {
+		matches := groupMatches[groupID]
+		period := LatestPeriod(matches)
+		if period == "" {
+			continue
+		}
+		standings := func(until string) map[string]Standing {
+			table, _ := Replay(teams, matches, until)
+			ranks := tableRanks(table)
+			standings := make(map[string]Standing, len(table))
+			for _, team := range table {
+				standings[team.TeamID] = Standing{Rank: ranks[team.TeamID], Score: float64(team.GoalDiff), Points: team.Points}
+			}
+			return standings
+		}
+		for teamID, movement := range Movements(standings(""), standings(period), period, PreviousPeriod(matches, period)) {
+			out[teamID] = movement
+		}
+	}
+	return out
+}
+
+// PowerMovements returns the movement of each team in the overall power
+// ranking since the previous period. Teams are ranked like /api/overall.
+// Without periods there is no movement.
+func PowerMovements(teams []model.TeamStats, matches []model.MatchResult, opts power.MetricOptions) map[string]model.Movement {
+	period := LatestPeriod(matches)
+	if period == "" {
+		return map[string]model.Movement{}
+	}
+	standings := func(until string) map[string]Standing {
+		replayed, kept := Replay(teams, matches, until)
+		metrics := power.ComputeMetricsWithOptions(replayed, kept, opts)
+		sort.SliceStable(replayed, func(i, j int) bool {
+			pi, pj := metrics[replayed[i].TeamID].PowerScore, metrics[replayed[j].TeamID].PowerScore
+			if pi != pj {
+				return pi > pj
+			}
+			if replayed[i].GoalDiff != replayed[j].GoalDiff {
+				return replayed[i].GoalDiff > replayed[j].GoalDiff
+			}
+			if replayed[i].Points != replayed[j].Points {
+				return replayed[i].Points > replayed[j].Points
+			}
+			return replayed[i].GoalsFor > replayed[j].GoalsFor
+		})
+		out := make(map[string]Standing, len(replayed))
+		for i, team := range replayed {
+			if _, ok := out[team.TeamID]; ok {
+				continue
+			}
+			out[team.TeamID] = Standing{Rank: i + 1, Score: metrics[team.TeamID].PowerScore, Points: team.Points}
+		}
+		return out
+	}
+	return Movements(standings(""), standings(period), period, PreviousPeriod(matches, period))
+}
+
+// Movers returns up to limit teams with the biggest rises and falls, largest
+// rank change first. Teams appear once even if listed in several groups.
+func Movers(teams []model.TeamStats, movements map[string]model.Movement, limit int) (rises, falls []Mover) {
+	rises, falls = []Mover{}, []Mover{}
+	seen := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		movement, ok := movements[team.TeamID]
+		if !ok || seen[team.TeamID] {
+			continue
+		}
+		seen[team.TeamID] = true
+		switch {
+		case movement.RankChange > 0:
+			rises = append(rises, Mover{Team: team, Movement: movement})
+		case movement.RankChange < 0:
+			falls = append(falls, Mover{Team: team, Movement: movement})
+		}
+	}
+	sort.SliceStable(rises, func(i, j int) bool {
+		if rises[i].Movement.RankChange != rises[j].Movement.RankChange {
+			return rises[i].Movement.RankChange > rises[j].Movement.RankChange
+		}
+		return rises[i].Movement.ScoreDelta > rises[j].Movement.ScoreDelta
+	})
+	sort.SliceStable(falls, func(i, j int) bool {
+		if falls[i].Movement.RankChange != falls[j].Movement.RankChange {
+			return falls[i].Movement.RankChange < falls[j].Movement.RankChange
+		}
+		return falls[i].Movement.ScoreDelta < falls[j].Movement.ScoreDelta
+	})
+	if limit > 0 && len(rises) > limit {
+		rises = rises[:limit]
+	}
+	if limit > 0 && len(falls) > limit {
+		falls = falls[:limit]
+	}
+	return rises, falls
+}
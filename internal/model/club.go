@@ -0,0 +1,34 @@
+package model
+
+import "regexp"
+
+// Club sources.
+const (
+	ClubSourceFussballDE = "fussball.de"
+	ClubSourceName       = "name"
+	ClubSourceAlias      = "alias"
+)
+
+// Club groups the teams of one club across groups and competitions.
+type Club struct {
+	// ID is the fussball.de club ID when known and a slug of the club name
+	// otherwise.
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	LogoURL string `json:"logoUrl,omitempty"`
+	// Source tells how the club was identified.
+	Source  string   `json:"source"`
+	TeamIDs []string `json:"teamIds"`
+}
+
+var clubLogoRegex = regexp.MustCompile(`/getLogo/.*?/id/([A-Z0-9]+)`)
+
+// ClubIDFromLogoURL extracts the fussball.de club ID from a club logo URL,
+// e.g. ".../action/getLogo/format/0/id/00ES8GN9F000009UVV0AG08LVUPGND5I/...".
+func ClubIDFromLogoURL(logoURL string) string {
+	matches := clubLogoRegex.FindStringSubmatch(logoURL)
+	if len(matches) == 2 {
+		return matches[1]
+	}
+	return ""
+}
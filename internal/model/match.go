@@ -1,6 +1,8 @@
 package model
 
 import (
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -30,6 +32,10 @@ type MatchResult struct {
 	URL         string      `json:"url"`
 	MatchDate   string      `json:"matchDate,omitempty"`
 	MatchdayTag string      `json:"matchdayTag,omitempty"`
+	// Venue is the pitch named on the match page, if any.
+	Venue string `json:"venue,omitempty"`
+	// Neutral marks matches without a home side, e.g. tournament games.
+	Neutral bool `json:"neutral,omitempty"`
 }
 
 // Played reports whether the match has a numeric result.
@@ -101,3 +107,52 @@ func buildAggregateMap(matches []MatchResult) map[string]aggregatedStats {
 	}
 	return stats
 }
+
+// SortChronologically orders matches by date, matchday and ID so rating
+// methods can replay them in the order they were played. A match without a
+// date takes the earliest date of its group's matchday, see DateResolver;
+// matches still without one come last. Matchdays compare as numbers.
+func SortChronologically(matches []MatchResult) {
+	dateOf := DateResolver(matches)
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		di, dj := dateOf(matches[i]), dateOf(matches[j])
+		if (di == "") != (dj == "") {
+			return di != ""
+		}
+		if di != dj {
+			return di < dj
+		}
+		if ti, tj := matches[i].MatchdayTag, matches[j].MatchdayTag; ti != tj {
+			ni, errI := strconv.Atoi(ti)
+			nj, errJ := strconv.Atoi(tj)
+			if errI == nil && errJ == nil {
+				return ni < nj
+			}
+			return ti < tj
+		}
+		return matches[i].ID < matches[j].ID
+	})
+}
+
+// DateResolver returns the date a match of matches counts as played on: its
+// MatchDate or, without one, the earliest date of its group's matchday. The
+// date is "" when neither is known.
+func DateResolver(matches []MatchResult) func(MatchResult) string {
+	matchdayDates := make(map[string]string)
+	for _, m := range matches {
+		if m.MatchDate == "" || m.MatchdayTag == "" {
+			continue
+		}
+		key := m.GroupID + "/" + m.MatchdayTag
+		if date, ok := matchdayDates[key]; !ok || m.MatchDate < date {
+			matchdayDates[key] = m.MatchDate
+		}
+	}
+	return func(m MatchResult) string {
+		if m.MatchDate != "" {
+			return m.MatchDate
+		}
+		return matchdayDates[m.GroupID+"/"+m.MatchdayTag]
+	}
+}
@@ -34,6 +34,7 @@ type TeamStats struct {
 	TeamID       string    `json:"teamId"`
 	TeamName     string    `json:"teamName"`
 	LogoURL      string    `json:"logoUrl,omitempty"`
+	ClubID       string    `json:"clubId,omitempty"`
 	Rank         int       `json:"rank"`
 	Games        int       `json:"games"`
 	Wins         int       `json:"wins"`
@@ -53,6 +54,24 @@ type MetricSet struct {
 	Dominance  float64       `json:"dominance"`
 	Normalized NormalizedSet `json:"normalized"`
 	PowerScore float64       `json:"powerScore"`
+	// LowSample marks teams with too few games for reliable metrics.
+	LowSample bool `json:"lowSample,omitempty"`
+	// Intervals holds 90% credible intervals of the raw metrics when
+	// shrinkage is enabled.
+	Intervals *MetricIntervals `json:"intervals,omitempty"`
+}
+
+// Interval is a lower and upper bound.
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// MetricIntervals stores credible intervals for the raw metrics.
+type MetricIntervals struct {
+	Offense   Interval `json:"offense"`
+	Defense   Interval `json:"defense"`
+	Dominance Interval `json:"dominance"`
 }
 
 // NormalizedSet stores 0..1 normalized metrics.
@@ -67,4 +86,54 @@ type TeamPower struct {
 	Team           TeamStats `json:"team"`
 	GroupMetrics   MetricSet `json:"groupMetrics"`
 	OverallMetrics MetricSet `json:"overallMetrics"`
+	Form           *Form     `json:"form,omitempty"`
+	Movement       *Movement `json:"movement,omitempty"`
+}
+
+// Movement compares a team's standing with the one before the latest period
+// of matches. RankChange is positive when the team climbed; ScoreDelta is the
+// change of the ranking's score, e.g. the power score or the goal difference
+// for tables.
+type Movement struct {
+	Period         string `json:"period"`
+	PreviousPeriod string `json:"previousPeriod,omitempty"`
+	Rank           int    `json:"rank"`
+	PreviousRank   int    `json:"previousRank"`
+	RankChange     int    `json:"rankChange"`
+	// Trend is "up", "down" or "same".
+	Trend       string  `json:"trend"`
+	ScoreDelta  float64 `json:"scoreDelta"`
+	PointsDelta int     `json:"pointsDelta"`
+}
+
+// Form summarizes how a team has been doing recently.
+type Form struct {
+	// Per-game averages where a match's weight halves every half-life.
+	Points       float64 `json:"points"`
+	GoalsFor     float64 `json:"goalsFor"`
+	GoalsAgainst float64 `json:"goalsAgainst"`
+	GoalDiff     float64 `json:"goalDiff"`
+	// Weight is the effective number of games behind the averages.
+	Weight float64 `json:"weight"`
+	// Last covers the most recent games only.
+	Last FormTable `json:"last"`
+	// Streak is the current run of equal results, e.g. "W3".
+	Streak string `json:"streak"`
+	// Decayed is false when no match had a date or matchday: the averages
+	// then weigh every game the same, and Last and Streak follow the match
+	// IDs rather than the order of play.
+	Decayed bool `json:"decayed"`
+}
+
+// FormTable is a plain table over the last few games.
+type FormTable struct {
+	Games        int `json:"games"`
+	Wins         int `json:"wins"`
+	Draws        int `json:"draws"`
+	Losses       int `json:"losses"`
+	GoalsFor     int `json:"goalsFor"`
+	GoalsAgainst int `json:"goalsAgainst"`
+	Points       int `json:"points"`
+	// Results lists W, D and L from oldest to newest.
+	Results string `json:"results"`
 }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+var romanNumerals = map[string]int{
+	"i": 1, "ii": 2, "iii": 3, "iv": 4, "v": 5,
+	"vi": 6, "vii": 7, "viii": 8, "ix": 9, "x": 10,
+}
+
+// TeamNameKey normalizes a team name into "<club>|<team number>" so that the
+// same team can be recognized across competitions, e.g. "KSV Baunatal II" in
+// the league and "KSV Baunatal 2" in the indoor tournament. Teams without a
+// number count as the first team.
+func TeamNameKey(name string) string {
+	club, number := splitTeamName(name)
+	if club == "" {
+		return ""
+	}
+	return club + "|" + strconv.Itoa(number)
+}
+
+// ClubName returns the club part of a team name with its original spelling,
+// e.g. "KSV Baunatal" for "KSV Baunatal II".
+func ClubName(name string) string {
+	tokens := strings.Fields(strings.ReplaceAll(name, "\u200b", ""))
+	for len(tokens) > 1 {
+		last := strings.ToLower(tokens[len(tokens)-1])
+		if last == "zg." || last == "zg" {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		if _, ok := romanNumerals[last]; ok {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		if n, err := strconv.Atoi(last); err == nil && n > 0 && n < 20 && !strings.HasPrefix(last, "0") {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		break
+	}
+	return strings.Join(tokens, " ")
+}
+
+func splitTeamName(name string) (string, int) {
+	name = strings.ReplaceAll(name, "\u200b", "")
+	tokens := strings.Fields(strings.ToLower(strings.TrimSpace(name)))
+
+	number := 0
+	for len(tokens) > 1 {
+		last := tokens[len(tokens)-1]
+		if last == "zg." || last == "zg" {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		if number == 0 {
+			if n, ok := romanNumerals[last]; ok {
+				number = n
+				tokens = tokens[:len(tokens)-1]
+				continue
+			}
+			if n, err := strconv.Atoi(last); err == nil && n > 0 && n < 20 && !strings.HasPrefix(last, "0") {
+				number = n
+				tokens = tokens[:len(tokens)-1]
+				continue
+			}
+		}
+		break
+	}
+	if number == 0 {
+		number = 1
+	}
+
+	// Abbreviated founding years ("SV Kaufungen 07") are not used consistently.
+	kept := tokens[:0]
+	for _, tok := range tokens {
+		if len(tok) == 2 && tok[0] == '0' && tok[1] >= '0' && tok[1] <= '9' {
+			continue
+		}
+		kept = append(kept, tok)
+	}
+	return strings.Join(kept, " "), number
+}
@@ -0,0 +1,341 @@
+package power
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// CalibrationInput bundles the league and indoor data used to calibrate
+// league groups against each other.
+type CalibrationInput struct {
+	LeagueTeams   []model.TeamStats
+	LeagueMatches []model.MatchResult
+	IndoorTeams   []model.TeamStats
+	IndoorMatches []model.MatchResult
+	Model         GoalModelOptions
+}
+
+// CalibratedTeam is a league team rated on a scale shared by all groups.
+type CalibratedTeam struct {
+	Team model.TeamStats `json:"team"`
+	// Rating is the goal-difference rating fitted on league and indoor matches.
+	Rating float64 `json:"rating"`
+	// LeagueRating is the rating fitted on league matches only; it is only
+	// comparable within the team's own group.
+	LeagueRating float64 `json:"leagueRating"`
+	GroupOffset  float64 `json:"groupOffset"`
+	IndoorGames  int     `json:"indoorGames"`
+}
+
+// GroupOffset is the estimated strength of a league group relative to the
+// average team.
+type GroupOffset struct {
+	GroupID   string  `json:"groupId"`
+	GroupName string  `json:"groupName"`
+	Offset    float64 `json:"offset"`
+	Teams     int     `json:"teams"`
+	// LinkedTeams counts teams of the group that played indoor matches.
+	LinkedTeams int `json:"linkedTeams"`
+	// Linked reports whether the group is connected to at least one other group.
+	Linked bool `json:"linked"`
+}
+
+// GroupLink counts indoor matches between teams of two league groups.
+type GroupLink struct {
+	GroupA  string `json:"groupA"`
+	GroupB  string `json:"groupB"`
+	Matches int    `json:"matches"`
+}
+
+// CalibrationReport explains how much cross-group information was available.
+type CalibrationReport struct {
+	LeagueTeams       int         `json:"leagueTeams"`
+	IndoorTeams       int         `json:"indoorTeams"`
+	MatchedByID       int         `json:"matchedById"`
+	MatchedByName     int         `json:"matchedByName"`
+	UnmatchedIndoor   []string    `json:"unmatchedIndoor"`
+	IndoorMatches     int         `json:"indoorMatches"`
+	CrossGroupMatches int         `json:"crossGroupMatches"`
+	GroupLinks        []GroupLink `json:"groupLinks"`
+	// Components lists the league groups that are connected through matches.
+	// A single component means every group can be compared with every other.
+	Components [][]string `json:"components"`
+}
+
+// CalibrationResult is the output of Calibrate.
+type CalibrationResult struct {
+	Teams  []CalibratedTeam  `json:"teams"`
+	Groups []GroupOffset     `json:"groups"`
+	Report CalibrationReport `json:"report"`
+}
+
+// MatchIndoorTeams maps indoor team IDs to league team IDs. Teams are matched
+// by ID first and by normalized name (club plus team number) second. The
+// fussball.de club ID stands in for the club name where both teams have one,
+// which catches abbreviations like "Eintr.Baunatal II".
+func MatchIndoorTeams(league, indoor []model.TeamStats) (mapping map[string]string, byID, byName int) {
+	mapping = make(map[string]string)
+	leagueIDs := make(map[string]struct{}, len(league))
+	leagueNames := make(map[string]string, len(league))
+	ambiguous := make(map[string]struct{})
+	for _, team := range league {
+		leagueIDs[team.TeamID] = struct{}{}
+		for _, key := range []string{model.TeamNameKey(team.TeamName), clubTeamKey(team)} {
+			if key == "" {
+				continue
+			}
+			if existing, ok := leagueNames[key]; ok && existing != team.TeamID {
+				ambiguous[key] = struct{}{}
+			}
+			leagueNames[key] = team.TeamID
+		}
+	}
+
+	for _, team := range indoor {
+		if _, ok := mapping[team.TeamID]; ok {
+			continue
+		}
+		if _, ok := leagueIDs[team.TeamID]; ok {
+			mapping[team.TeamID] = team.TeamID
+			byID++
+			continue
+		}
+		for _, key := range []string{model.TeamNameKey(team.TeamName), clubTeamKey(team)} {
+			if _, bad := ambiguous[key]; bad || key == "" {
+				continue
+			}
+			if id, ok := leagueNames[key]; ok {
+				mapping[team.TeamID] = id
+				byName++
+				break
+			}
+		}
+	}
+	return mapping, byID, byName
+}
+
+// clubTeamKey is "club:<club ID>|<team number>", or "" without club ID.
+func clubTeamKey(team model.TeamStats) string {
+	key := model.TeamNameKey(team.TeamName)
+	if team.ClubID == "" || key == "" {
+		return ""
+	}
+	return "club:" + team.ClubID + key[strings.LastIndex(key, "|"):]
+}
+
+// TranslateMatches returns copies of matches with team IDs replaced according
+// to mapping, e.g. indoor IDs by the league IDs from MatchIndoorTeams.
+func TranslateMatches(matches []model.MatchResult, mapping map[string]string) []model.MatchResult {
+	out := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if id, ok := mapping[m.HomeTeamID]; ok {
+			m.HomeTeamID = id
+		}
+		if id, ok := mapping[m.AwayTeamID]; ok {
+			m.AwayTeamID = id
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// CalibratedModel fits the goal model of Calibrate: league matches plus the
+// indoor matches, with indoor teams translated to their league IDs, so teams
+// of groups that never meet in the league are rated on one scale.
+func CalibratedModel(in CalibrationInput) GoalModel {
+	mapping, _, _ := MatchIndoorTeams(in.LeagueTeams, in.IndoorTeams)
+	matches := append([]model.MatchResult(nil), in.LeagueMatches...)
+	matches = append(matches, TranslateMatches(in.IndoorMatches, mapping)...)
+	return FitGoalModel(matches, in.Model)
+}
+
+// Calibrate fits a goal model on league matches plus indoor matches, where the
+// indoor tournament acts as a bridge between league groups that never meet.
+// Group offsets are the mean combined rating of each group's teams.
+func Calibrate(in CalibrationInput) CalibrationResult {
+	mapping, byID, byName := MatchIndoorTeams(in.LeagueTeams, in.IndoorTeams)
+
+	groupOf := make(map[string]string, len(in.LeagueTeams))
+	groupNames := make(map[string]string)
+	for _, team := range in.LeagueTeams {
+		groupOf[team.TeamID] = team.GroupID
+		groupNames[team.GroupID] = team.GroupName
+	}
+
+	report := CalibrationReport{
+		LeagueTeams:     len(in.LeagueTeams),
+		IndoorTeams:     len(in.IndoorTeams),
+		MatchedByID:     byID,
+		MatchedByName:   byName,
+		UnmatchedIndoor: []string{},
+		GroupLinks:      []GroupLink{},
+	}
+	for _, team := range in.IndoorTeams {
+		if _, ok := mapping[team.TeamID]; !ok {
+			report.UnmatchedIndoor = append(report.UnmatchedIndoor, team.TeamName)
+		}
+	}
+	sort.Strings(report.UnmatchedIndoor)
+
+	translated := make([]model.MatchResult, 0, len(in.IndoorMatches))
+	indoorGames := make(map[string]int)
+	links := make(map[[2]string]int)
+	for _, m := range in.IndoorMatches {
+		if !m.Played() {
+			continue
+		}
+		if id, ok := mapping[m.HomeTeamID]; ok {
+			m.HomeTeamID = id
+		}
+		if id, ok := mapping[m.AwayTeamID]; ok {
+			m.AwayTeamID = id
+		}
+		translated = append(translated, m)
+		report.IndoorMatches++
+		indoorGames[m.HomeTeamID]++
+		indoorGames[m.AwayTeamID]++
+
+		ga, okA := groupOf[m.HomeTeamID]
+		gb, okB := groupOf[m.AwayTeamID]
+		if okA && okB && ga != gb {
+			report.CrossGroupMatches++
+			key := [2]string{ga, gb}
+			if gb < ga {
+				key = [2]string{gb, ga}
+			}
+			links[key]++
+		}
+	}
+	for key, count := range links {
+		report.GroupLinks = append(report.GroupLinks, GroupLink{GroupA: key[0], GroupB: key[1], Matches: count})
+	}
+	sort.Slice(report.GroupLinks, func(i, j int) bool {
+		if report.GroupLinks[i].GroupA != report.GroupLinks[j].GroupA {
+			return report.GroupLinks[i].GroupA < report.GroupLinks[j].GroupA
+		}
+		return report.GroupLinks[i].GroupB < report.GroupLinks[j].GroupB
+	})
+
+	combinedMatches := make([]model.MatchResult, 0, len(in.LeagueMatches)+len(translated))
+	combinedMatches = append(combinedMatches, in.LeagueMatches...)
+	combinedMatches = append(combinedMatches, translated...)
+
+	leagueOnly := FitGoalModel(in.LeagueMatches, in.Model)
+	combined := FitGoalModel(combinedMatches, in.Model)
+
+	report.Components = groupComponents(in.LeagueTeams, combinedMatches, groupOf)
+	componentSize := make(map[string]int)
+	for _, component := range report.Components {
+		for _, groupID := range component {
+			componentSize[groupID] = len(component)
+		}
+	}
+
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	linked := make(map[string]int)
+	for _, team := range in.LeagueTeams {
+		sums[team.GroupID] += combined.Rating(team.TeamID)
+		counts[team.GroupID]++
+		if indoorGames[team.TeamID] > 0 {
+			linked[team.GroupID]++
+		}
+	}
+
+	offsets := make(map[string]float64, len(counts))
+	result := CalibrationResult{Report: report}
+	for groupID, count := range counts {
+		offsets[groupID] = sums[groupID] / float64(count)
+		result.Groups = append(result.Groups, GroupOffset{
+			GroupID:     groupID,
+			GroupName:   groupNames[groupID],
+			Offset:      offsets[groupID],
+			Teams:       count,
+			LinkedTeams: linked[groupID],
+			Linked:      componentSize[groupID] > 1,
+		})
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		return result.Groups[i].Offset > result.Groups[j].Offset
+	})
+
+	for _, team := range in.LeagueTeams {
+		result.Teams = append(result.Teams, CalibratedTeam{
+			Team:         team,
+			Rating:       combined.Rating(team.TeamID),
+			LeagueRating: leagueOnly.Rating(team.TeamID),
+			GroupOffset:  offsets[team.GroupID],
+			IndoorGames:  indoorGames[team.TeamID],
+		})
+	}
+	sort.Slice(result.Teams, func(i, j int) bool {
+		if result.Teams[i].Rating != result.Teams[j].Rating {
+			return result.Teams[i].Rating > result.Teams[j].Rating
+		}
+		return result.Teams[i].Team.TeamName < result.Teams[j].Team.TeamName
+	})
+
+	return result
+}
+
+// groupComponents returns the connected components of league groups, where two
+// groups are connected if any chain of matches links teams of both.
+func groupComponents(teams []model.TeamStats, matches []model.MatchResult, groupOf map[string]string) [][]string {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(id string) string {
+		p, ok := parent[id]
+		if !ok {
+			parent[id] = id
+			return id
+		}
+		if p == id {
+			return id
+		}
+		root := find(p)
+		parent[id] = root
+		return root
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[ra] = rb
+		}
+	}
+
+	for _, team := range teams {
+		union("group:"+team.GroupID, team.TeamID)
+	}
+	for _, m := range matches {
+		if m.HomeTeamID == "" || m.AwayTeamID == "" {
+			continue
+		}
+		union(m.HomeTeamID, m.AwayTeamID)
+	}
+
+	byRoot := make(map[string][]string)
+	seen := make(map[string]struct{})
+	for _, groupID := range groupOf {
+		if _, ok := seen[groupID]; ok {
+			continue
+		}
+		seen[groupID] = struct{}{}
+		root := find("group:" + groupID)
+		byRoot[root] = append(byRoot[root], groupID)
+	}
+
+	components := make([][]string, 0, len(byRoot))
+	for _, groupIDs := range byRoot {
+		sort.Strings(groupIDs)
+		components = append(components, groupIDs)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return strings.Join(components[i], ",") < strings.Join(components[j], ",")
+	})
+	return components
+}
@@ -11,60 +11,207 @@ type EloResult struct {
 	Games  int
 }
 
-// ComputeElo computes a simple Elo rating for each team based on played matches.
-// Note: without inter-group matches, Elo cannot fully calibrate group strength,
-// but it is still useful to compare methods side-by-side.
-func ComputeElo(matches []model.MatchResult, initialRating, kFactor float64) map[string]EloResult {
-	ratings := make(map[string]EloResult)
+// EloParams configures the Elo update.
+type EloParams struct {
+	InitialRating float64
+	KFactor       float64
+	// MarginSlope is the extra weight per goal of margin beyond the first.
+	MarginSlope float64
+	// MarginCap bounds the goal-difference multiplier; 1 disables it.
+	MarginCap float64
+	// Margin dampens the goal difference before the multiplier is applied.
+	Margin MarginConfig
+	// HomeAdvantage is added to the home team's rating when computing the
+	// expected score. It is ignored for neutral matches.
+	HomeAdvantage float64
+}
 
-	get := func(teamID string) EloResult {
-		if r, ok := ratings[teamID]; ok {
-			return r
-		}
-		return EloResult{Rating: initialRating}
+// DefaultEloParams returns the parameters used by the overall Elo views.
+func DefaultEloParams() EloParams {
+	return EloParams{InitialRating: 1500, KFactor: 20, MarginSlope: 0.5, MarginCap: 3, Margin: DefaultMarginConfig()}
+}
+
+// EloHistoryEntry records a team's rating right after one of its matches.
+type EloHistoryEntry struct {
+	MatchID      string  `json:"matchId"`
+	MatchDate    string  `json:"matchDate,omitempty"`
+	MatchdayTag  string  `json:"matchdayTag,omitempty"`
+	OpponentID   string  `json:"opponentId"`
+	Opponent     string  `json:"opponent"`
+	Home         bool    `json:"home"`
+	GoalsFor     int     `json:"goalsFor"`
+	GoalsAgainst int     `json:"goalsAgainst"`
+	Expected     float64 `json:"expected"`
+	RatingBefore float64 `json:"ratingBefore"`
+	Rating       float64 `json:"rating"`
+	Delta        float64 `json:"delta"`
+}
+
+// EloRater applies Elo updates match by match. It lets callers interleave
+// predictions and updates, e.g. when replaying a season.
+type EloRater struct {
+	params  EloParams
+	ratings map[string]EloResult
+}
+
+// NewEloRater creates a rater where every team starts at params.InitialRating.
+func NewEloRater(params EloParams) *EloRater {
+	return &EloRater{params: params, ratings: make(map[string]EloResult)}
+}
+
+// Rating returns the current rating of a team.
+func (e *EloRater) Rating(teamID string) EloResult {
+	if r, ok := e.ratings[teamID]; ok {
+		return r
 	}
+	return EloResult{Rating: e.params.InitialRating}
+}
 
-	for _, m := range matches {
-		if !m.Played() {
-			continue
-		}
-		if m.HomeTeamID == "" || m.AwayTeamID == "" {
-			continue
-		}
+// Expected returns the expected score of the home team in m, including the
+// home advantage unless the match is neutral.
+func (e *EloRater) Expected(m model.MatchResult) float64 {
+	return EloExpected(e.Rating(m.HomeTeamID).Rating+e.homeAdvantage(m), e.Rating(m.AwayTeamID).Rating)
+}
 
-		ra := get(m.HomeTeamID)
-		rb := get(m.AwayTeamID)
-
-		expectedA := 1.0 / (1.0 + math.Pow(10, (rb.Rating-ra.Rating)/400.0))
-		actualA := 0.5
-		goalDiff := m.HomeScore - m.AwayScore
-		switch {
-		case goalDiff > 0:
-			actualA = 1
-		case goalDiff < 0:
-			actualA = 0
-		}
+func (e *EloRater) homeAdvantage(m model.MatchResult) float64 {
+	if m.Neutral {
+		return 0
+	}
+	return e.params.HomeAdvantage
+}
+
+// Apply updates both teams with the match result and returns the rating change
+// of the home team along with its pre-match expected score. Unplayed matches
+// are ignored.
+func (e *EloRater) Apply(m model.MatchResult) (delta, expected float64, ok bool) {
+	if !m.Played() {
+		return 0, 0, false
+	}
+	if m.HomeTeamID == "" || m.AwayTeamID == "" {
+		return 0, 0, false
+	}
+
+	ra := e.Rating(m.HomeTeamID)
+	rb := e.Rating(m.AwayTeamID)
 
-		mult := 1.0
-		if actualA != 0.5 {
-			d := math.Abs(float64(goalDiff))
-			if d > 1 {
-				mult = 1 + 0.5*(d-1)
-				if mult > 3 {
-					mult = 3
-				}
+	expectedA := EloExpected(ra.Rating+e.homeAdvantage(m), rb.Rating)
+	actualA := 0.5
+	goalDiff := m.HomeScore - m.AwayScore
+	switch {
+	case goalDiff > 0:
+		actualA = 1
+	case goalDiff < 0:
+		actualA = 0
+	}
+
+	mult := 1.0
+	if actualA != 0.5 {
+		d := e.params.Margin.Dampen(math.Abs(float64(goalDiff)))
+		if d > 1 {
+			mult = 1 + e.params.MarginSlope*(d-1)
+			if mult > e.params.MarginCap {
+				mult = e.params.MarginCap
 			}
 		}
+	}
 
-		delta := kFactor * mult * (actualA - expectedA)
-		ra.Rating += delta
-		rb.Rating -= delta
-		ra.Games++
-		rb.Games++
+	delta = e.params.KFactor * mult * (actualA - expectedA)
+	ra.Rating += delta
+	rb.Rating -= delta
+	ra.Games++
+	rb.Games++
 
-		ratings[m.HomeTeamID] = ra
-		ratings[m.AwayTeamID] = rb
+	e.ratings[m.HomeTeamID] = ra
+	e.ratings[m.AwayTeamID] = rb
+	return delta, expectedA, true
+}
+
+// Ratings returns a copy of all ratings seen so far.
+func (e *EloRater) Ratings() map[string]EloResult {
+	out := make(map[string]EloResult, len(e.ratings))
+	for id, r := range e.ratings {
+		out[id] = r
 	}
+	return out
+}
 
+// EloExpected returns the expected score of a team rated ra against rb.
+func EloExpected(ra, rb float64) float64 {
+	return 1.0 / (1.0 + math.Pow(10, (rb-ra)/400.0))
+}
+
+// ComputeElo computes a simple Elo rating for each team based on played matches,
+// without home advantage.
+// Note: without inter-group matches, Elo cannot fully calibrate group strength,
+// but it is still useful to compare methods side-by-side.
+func ComputeElo(matches []model.MatchResult, initialRating, kFactor float64) map[string]EloResult {
+	ratings, _ := ComputeEloHistory(matches, initialRating, kFactor)
 	return ratings
 }
+
+// ComputeEloHistory works like ComputeElo but additionally returns, per team,
+// the rating after every match in the order the matches were supplied.
+func ComputeEloHistory(matches []model.MatchResult, initialRating, kFactor float64) (map[string]EloResult, map[string][]EloHistoryEntry) {
+	params := DefaultEloParams()
+	params.InitialRating = initialRating
+	params.KFactor = kFactor
+	return ComputeEloWithParams(matches, params)
+}
+
+// ComputeEloWithParams is ComputeEloHistory with full control over the Elo parameters.
+func ComputeEloWithParams(matches []model.MatchResult, params EloParams) (map[string]EloResult, map[string][]EloHistoryEntry) {
+	rater := NewEloRater(params)
+	history := make(map[string][]EloHistoryEntry)
+
+	for _, m := range matches {
+		ra := rater.Rating(m.HomeTeamID)
+		rb := rater.Rating(m.AwayTeamID)
+		delta, expected, ok := rater.Apply(m)
+		if !ok {
+			continue
+		}
+
+		history[m.HomeTeamID] = append(history[m.HomeTeamID], EloHistoryEntry{
+			MatchID:      m.ID,
+			MatchDate:    m.MatchDate,
+			MatchdayTag:  m.MatchdayTag,
+			OpponentID:   m.AwayTeamID,
+			Opponent:     m.AwayTeam,
+			Home:         true,
+			GoalsFor:     m.HomeScore,
+			GoalsAgainst: m.AwayScore,
+			Expected:     expected,
+			RatingBefore: ra.Rating,
+			Rating:       ra.Rating + delta,
+			Delta:        delta,
+		})
+		history[m.AwayTeamID] = append(history[m.AwayTeamID], EloHistoryEntry{
+			MatchID:      m.ID,
+			MatchDate:    m.MatchDate,
+			MatchdayTag:  m.MatchdayTag,
+			OpponentID:   m.HomeTeamID,
+			Opponent:     m.HomeTeam,
+			GoalsFor:     m.AwayScore,
+			GoalsAgainst: m.HomeScore,
+			Expected:     1 - expected,
+			RatingBefore: rb.Rating,
+			Rating:       rb.Rating - delta,
+			Delta:        -delta,
+		})
+	}
+
+	return rater.Ratings(), history
+}
+
+// BiggestEloMove returns the history entry with the largest absolute delta.
+func BiggestEloMove(history []EloHistoryEntry) (EloHistoryEntry, bool) {
+	var best EloHistoryEntry
+	found := false
+	for _, entry := range history {
+		if !found || math.Abs(entry.Delta) > math.Abs(best.Delta) {
+			best = entry
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,243 @@
+package power
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// matchDateLayout is the format of model.MatchResult.MatchDate.
+const matchDateLayout = "2006-01-02"
+
+// FormOptions configures ComputeForm.
+type FormOptions struct {
+	// HalfLife is the age in days at which a match counts half.
+	HalfLife float64
+	// LastN is the number of games in the recent form table.
+	LastN int
+	// Reference is the date ages are measured from; zero uses the latest match.
+	Reference time.Time
+}
+
+// DefaultFormOptions returns a four-week half-life and a five-game table.
+func DefaultFormOptions() FormOptions {
+	return FormOptions{HalfLife: 28, LastN: 5}
+}
+
+// ParseFormOptions builds FormOptions from user input; empty values keep the
+// defaults.
+func ParseFormOptions(halfLife, lastN string) (FormOptions, error) {
+	opts := DefaultFormOptions()
+	if halfLife = strings.TrimSpace(halfLife); halfLife != "" {
+		v, err := strconv.ParseFloat(halfLife, 64)
+		if err != nil || v <= 0 {
+			return opts, fmt.Errorf("halfLife must be a positive number of days")
+		}
+		opts.HalfLife = v
+	}
+	if lastN = strings.TrimSpace(lastN); lastN != "" {
+		v, err := strconv.Atoi(lastN)
+		if err != nil || v <= 0 {
+			return opts, fmt.Errorf("last must be a positive number of games")
+		}
+		opts.LastN = v
+	}
+	return opts, nil
+}
+
+// ComputeForm returns the form of every team that played one of the matches.
+// Match ages come from MatchDate or, when no match has a date, a week per
+// matchday before the latest MatchdayTag. Without either, every game counts
+// the same and Form.Decayed is false. A team's games are taken oldest first
+// for the last-N table and the streak as well, so games that cannot be aged
+// count as the oldest everywhere.
+func ComputeForm(matches []model.MatchResult, opts FormOptions) map[string]model.Form {
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = DefaultFormOptions().HalfLife
+	}
+	if opts.LastN <= 0 {
+		opts.LastN = DefaultFormOptions().LastN
+	}
+
+	played := make([]model.MatchResult, 0, len(matches))
+	for _, m := range matches {
+		if m.Played() && m.HomeTeamID != "" && m.AwayTeamID != "" {
+			played = append(played, m)
+		}
+	}
+	model.SortChronologically(played)
+
+	ages, decayed := matchAges(played, opts.Reference)
+
+	type result struct {
+		goalsFor, goalsAgainst int
+		age                    float64
+	}
+	byTeam := make(map[string][]result)
+	for i, m := range played {
+		byTeam[m.HomeTeamID] = append(byTeam[m.HomeTeamID], result{m.HomeScore, m.AwayScore, ages[i]})
+		byTeam[m.AwayTeamID] = append(byTeam[m.AwayTeamID], result{m.AwayScore, m.HomeScore, ages[i]})
+	}
+
+	forms := make(map[string]model.Form, len(byTeam))
+	for teamID, results := range byTeam {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].age > results[j].age })
+		form := model.Form{Decayed: decayed}
+		var points, goalsFor, goalsAgainst float64
+		for _, res := range results {
+			w := math.Pow(0.5, res.age/opts.HalfLife)
+			form.Weight += w
+			points += w * float64(resultPoints(res.goalsFor, res.goalsAgainst))
+			goalsFor += w * float64(res.goalsFor)
+			goalsAgainst += w * float64(res.goalsAgainst)
+		}
+		if form.Weight > 0 {
+			form.Points = points / form.Weight
+			form.GoalsFor = goalsFor / form.Weight
+			form.GoalsAgainst = goalsAgainst / form.Weight
+			form.GoalDiff = form.GoalsFor - form.GoalsAgainst
+		}
+
+		start := len(results) - opts.LastN
+		if start < 0 {
+			start = 0
+		}
+		var letters strings.Builder
+		for _, res := range results[start:] {
+			t := &form.Last
+			t.Games++
+			t.GoalsFor += res.goalsFor
+			t.GoalsAgainst += res.goalsAgainst
+			t.Points += resultPoints(res.goalsFor, res.goalsAgainst)
+			letter := resultLetter(res.goalsFor, res.goalsAgainst)
+			switch letter {
+			case 'W':
+				t.Wins++
+			case 'D':
+				t.Draws++
+			default:
+				t.Losses++
+			}
+			letters.WriteByte(letter)
+		}
+		form.Last.Results = letters.String()
+
+		last := resultLetter(results[len(results)-1].goalsFor, results[len(results)-1].goalsAgainst)
+		run := 0
+		for i := len(results) - 1; i >= 0 && resultLetter(results[i].goalsFor, results[i].goalsAgainst) == last; i-- {
+			run++
+		}
+		form.Streak = fmt.Sprintf("%c%d", last, run)
+
+		forms[teamID] = form
+	}
+	return forms
+}
+
+// FormDecays reports whether ComputeForm can age the matches, i.e. whether a
+// played match has a date or a matchday.
+func FormDecays(matches []model.MatchResult) bool {
+	for _, m := range matches {
+		if !m.Played() {
+			continue
+		}
+		if _, err := time.Parse(matchDateLayout, m.MatchDate); err == nil {
+			return true
+		}
+		if tag, err := strconv.Atoi(strings.TrimSpace(m.MatchdayTag)); err == nil && tag > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAges returns the age in days of every (chronologically sorted) match,
+// and false when no match could be aged. Ages use one scale: dates when any
+// match has one, matchdays otherwise. A match without a date takes the one of
+// its matchday, see model.DateResolver. Matches the scale cannot place count
+// as the oldest ones.
+func matchAges(matches []model.MatchResult, reference time.Time) ([]float64, bool) {
+	dateOf := model.DateResolver(matches)
+	dates := make([]time.Time, len(matches))
+	tags := make([]int, len(matches))
+	latestTag := 0
+	for i, m := range matches {
+		if d, err := time.Parse(matchDateLayout, dateOf(m)); err == nil {
+			dates[i] = d
+		}
+		if tag, err := strconv.Atoi(strings.TrimSpace(m.MatchdayTag)); err == nil {
+			tags[i] = tag
+			if tag > latestTag {
+				latestTag = tag
+			}
+		}
+	}
+	latest := latestDate(dates)
+	if reference.IsZero() {
+		reference = latest
+	}
+
+	ages := make([]float64, len(matches))
+	oldest := 0.0
+	known := make([]bool, len(matches))
+	aged := false
+	for i := range matches {
+		switch {
+		case !latest.IsZero():
+			if dates[i].IsZero() {
+				continue
+			}
+			ages[i] = math.Max(reference.Sub(dates[i]).Hours()/24, 0)
+		case tags[i] > 0:
+			ages[i] = float64(latestTag-tags[i]) * 7
+		default:
+			continue
+		}
+		known[i] = true
+		aged = true
+		oldest = math.Max(oldest, ages[i])
+	}
+	for i := range ages {
+		if !known[i] {
+			ages[i] = oldest
+		}
+	}
+	return ages, aged
+}
+
+func latestDate(dates []time.Time) time.Time {
+	var latest time.Time
+	for _, d := range dates {
+		if d.After(latest) {
+			latest = d
+		}
+	}
+	return latest
+}
+
+func resultPoints(goalsFor, goalsAgainst int) int {
+	switch {
+	case goalsFor > goalsAgainst:
+		return 3
+	case goalsFor == goalsAgainst:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func resultLetter(goalsFor, goalsAgainst int) byte {
+	switch {
+	case goalsFor > goalsAgainst:
+		return 'W'
+	case goalsFor == goalsAgainst:
+		return 'D'
+	default:
+		return 'L'
+	}
+}
@@ -0,0 +1,262 @@
+package power
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Normalization selects how raw metrics are mapped onto the 0..1 scale.
+type Normalization string
+
+const (
+	// NormalizeMinMax scales linearly between the smallest and largest value.
+	NormalizeMinMax Normalization = "minmax"
+	// NormalizeZScore maps z-scores through the normal CDF.
+	NormalizeZScore Normalization = "zscore"
+	// NormalizePercentile uses the percentile rank, ties share their average rank.
+	NormalizePercentile Normalization = "percentile"
+	// NormalizeRobust maps (x - median) / MAD through the normal CDF, so a single
+	// outlier does not squash everybody else.
+	NormalizeRobust Normalization = "robust"
+)
+
+// Formula describes how the metrics combine into the PowerScore.
+type Formula struct {
+	Name          string        `json:"name"`
+	Weights       Weights       `json:"weights"`
+	Normalization Normalization `json:"normalization"`
+}
+
+// formulaPresets are the named formulas selectable via query parameter or config.
+var formulaPresets = map[string]Formula{
+	"default":    {Name: "default", Weights: DefaultWeights(), Normalization: NormalizeMinMax},
+	"documented": {Name: "documented", Weights: Weights{Offense: 0.4, Defense: 0.3, Dominance: 0.3}, Normalization: NormalizeMinMax},
+	"balanced":   {Name: "balanced", Weights: Weights{Offense: 1.0 / 3, Defense: 1.0 / 3, Dominance: 1.0 / 3}, Normalization: NormalizeZScore},
+	"robust":     {Name: "robust", Weights: Weights{Offense: 0.4, Defense: 0.4, Dominance: 0.2}, Normalization: NormalizeRobust},
+	"percentile": {Name: "percentile", Weights: Weights{Offense: 0.4, Defense: 0.4, Dominance: 0.2}, Normalization: NormalizePercentile},
+}
+
+// DefaultFormula returns the historic 0.4/0.4/0.2 min-max formula.
+func DefaultFormula() Formula {
+	return formulaPresets["default"]
+}
+
+// FormulaPresets returns all named formulas sorted by name.
+func FormulaPresets() []Formula {
+	out := make([]Formula, 0, len(formulaPresets))
+	for _, f := range formulaPresets {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// ResolveFormula starts from base, applies a named preset and then overrides
+// weights ("offense,defense,dominance") and normalization. Empty inputs are
+// ignored. Custom weights are rescaled to sum to 1.
+func ResolveFormula(base Formula, preset, weights, normalization string) (Formula, error) {
+	f := base
+	if preset = strings.ToLower(strings.TrimSpace(preset)); preset != "" {
+		p, ok := formulaPresets[preset]
+		if !ok {
+			return f, fmt.Errorf("unknown formula %q", preset)
+		}
+		f = p
+	}
+
+	if weights = strings.TrimSpace(weights); weights != "" {
+		parts := strings.Split(weights, ",")
+		if len(parts) != 3 {
+			return f, fmt.Errorf("weights must be offense,defense,dominance")
+		}
+		values := make([]float64, 3)
+		sum := 0.0
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || v < 0 {
+				return f, fmt.Errorf("invalid weight %q", part)
+			}
+			values[i] = v
+			sum += v
+		}
+		if sum == 0 {
+			return f, fmt.Errorf("weights must not all be zero")
+		}
+		f.Weights = Weights{Offense: values[0] / sum, Defense: values[1] / sum, Dominance: values[2] / sum}
+		f.Name = "custom"
+	}
+
+	if normalization = strings.ToLower(strings.TrimSpace(normalization)); normalization != "" {
+		switch Normalization(normalization) {
+		case NormalizeMinMax, NormalizeZScore, NormalizePercentile, NormalizeRobust:
+		default:
+			return f, fmt.Errorf("unknown normalization %q", normalization)
+		}
+		if Normalization(normalization) != f.Normalization {
+			f.Normalization = Normalization(normalization)
+			f.Name = "custom"
+		}
+	}
+
+	return f, nil
+}
+
+// String renders the formula, e.g. "0.40*offense + 0.40*defense + 0.20*dominance (minmax)".
+func (f Formula) String() string {
+	return fmt.Sprintf("%.2f*offense + %.2f*defense + %.2f*dominance (%s)",
+		f.Weights.Offense, f.Weights.Defense, f.Weights.Dominance, f.normalization())
+}
+
+// MarshalJSON adds the rendered expression so every response states the
+// formula that produced it.
+func (f Formula) MarshalJSON() ([]byte, error) {
+	type plain Formula
+	return json.Marshal(struct {
+		plain
+		Expression string `json:"expression"`
+	}{plain(f), f.String()})
+}
+
+func (f Formula) normalization() Normalization {
+	if f.Normalization == "" {
+		return NormalizeMinMax
+	}
+	return f.Normalization
+}
+
+func (f Formula) normalize(values []float64, valid []bool) []float64 {
+	switch f.normalization() {
+	case NormalizeZScore:
+		return normalizeZScore(values, valid)
+	case NormalizePercentile:
+		return normalizePercentile(values, valid)
+	case NormalizeRobust:
+		return normalizeRobust(values, valid)
+	default:
+		return normalize(values, valid)
+	}
+}
+
+func validValues(values []float64, valid []bool) []float64 {
+	out := make([]float64, 0, len(values))
+	for i, v := range values {
+		if valid[i] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// normalizeCentered maps (x - center) / scale through the normal CDF. A zero
+// scale means all values are equal and every valid team gets 0.5.
+func normalizeCentered(values []float64, valid []bool, center, scale float64) []float64 {
+	normalized := make([]float64, len(values))
+	for i, v := range values {
+		if !valid[i] {
+			continue
+		}
+		if scale == 0 {
+			normalized[i] = 0.5
+			continue
+		}
+		normalized[i] = normalCDF((v - center) / scale)
+	}
+	return normalized
+}
+
+func normalizeZScore(values []float64, valid []bool) []float64 {
+	vals := validValues(values, valid)
+	if len(vals) == 0 {
+		return make([]float64, len(values))
+	}
+	mean := 0.0
+	for _, v := range vals {
+		mean += v
+	}
+	mean /= float64(len(vals))
+	variance := 0.0
+	for _, v := range vals {
+		variance += (v - mean) * (v - mean)
+	}
+	return normalizeCentered(values, valid, mean, math.Sqrt(variance/float64(len(vals))))
+}
+
+func normalizeRobust(values []float64, valid []bool) []float64 {
+	vals := validValues(values, valid)
+	if len(vals) == 0 {
+		return make([]float64, len(values))
+	}
+	med := median(vals)
+	deviations := make([]float64, len(vals))
+	meanAbs := 0.0
+	for i, v := range vals {
+		deviations[i] = math.Abs(v - med)
+		meanAbs += deviations[i]
+	}
+	meanAbs /= float64(len(vals))
+
+	// 1.4826 makes the MAD consistent with the standard deviation for normal
+	// data. With more than half the values tied, fall back to the mean
+	// absolute deviation (scaled by sqrt(pi/2) for the same reason).
+	scale := 1.4826 * median(deviations)
+	if scale == 0 {
+		scale = 1.2533 * meanAbs
+	}
+	return normalizeCentered(values, valid, med, scale)
+}
+
+func normalizePercentile(values []float64, valid []bool) []float64 {
+	normalized := make([]float64, len(values))
+	idx := make([]int, 0, len(values))
+	for i := range values {
+		if valid[i] {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) == 0 {
+		return normalized
+	}
+	if len(idx) == 1 {
+		normalized[idx[0]] = 0.5
+		return normalized
+	}
+
+	sort.SliceStable(idx, func(a, b int) bool {
+		return values[idx[a]] < values[idx[b]]
+	})
+	for start := 0; start < len(idx); {
+		end := start
+		for end+1 < len(idx) && values[idx[end+1]] == values[idx[start]] {
+			end++
+		}
+		rank := float64(start+end) / 2
+		for k := start; k <= end; k++ {
+			normalized[idx[k]] = rank / float64(len(idx)-1)
+		}
+		start = end + 1
+	}
+	return normalized
+}
+
+func median(vals []float64) float64 {
+	sorted := append([]float64(nil), vals...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * (1 + math.Erf(z/math.Sqrt2))
+}
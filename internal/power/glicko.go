@@ -0,0 +1,243 @@
+package power
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// glickoScale converts between the Glicko and Glicko-2 rating scales.
+const glickoScale = 173.7178
+
+// GlickoPeriod selects how matches are bucketed into rating periods.
+type GlickoPeriod string
+
+const (
+	// GlickoPeriodWeek groups matches by ISO week of their MatchDate.
+	GlickoPeriodWeek GlickoPeriod = "week"
+	// GlickoPeriodMatchday groups matches by their MatchdayTag.
+	GlickoPeriodMatchday GlickoPeriod = "matchday"
+)
+
+// GlickoConfig holds the Glicko-2 system parameters.
+type GlickoConfig struct {
+	InitialRating     float64
+	InitialDeviation  float64
+	InitialVolatility float64
+	// Tau constrains how quickly volatility may change between periods.
+	Tau    float64
+	Period GlickoPeriod
+}
+
+// DefaultGlickoConfig returns the parameters suggested by Glickman.
+func DefaultGlickoConfig() GlickoConfig {
+	return GlickoConfig{
+		InitialRating:     1500,
+		InitialDeviation:  350,
+		InitialVolatility: 0.06,
+		Tau:               0.5,
+		Period:            GlickoPeriodWeek,
+	}
+}
+
+// GlickoResult carries a Glicko-2 rating together with its uncertainty.
+type GlickoResult struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	Games      int
+}
+
+type glickoGame struct {
+	opponentMu  float64
+	opponentPhi float64
+	score       float64
+}
+
+// ComputeGlicko computes Glicko-2 ratings. Matches are grouped into rating
+// periods (see GlickoPeriod); a match without a date takes the one of its
+// matchday (see model.DateResolver). Matches still without a period share a
+// single one that is processed last, as they are in Elo and form. Teams that
+// sit out a period keep their rating but grow more uncertain.
+func ComputeGlicko(matches []model.MatchResult, cfg GlickoConfig) map[string]GlickoResult {
+	ratings := make(map[string]GlickoResult)
+
+	dateOf := model.DateResolver(matches)
+	periods := make(map[string][]model.MatchResult)
+	for _, m := range matches {
+		if !m.Played() || m.HomeTeamID == "" || m.AwayTeamID == "" {
+			continue
+		}
+		key := glickoPeriodKey(m, dateOf(m), cfg.Period)
+		periods[key] = append(periods[key], m)
+	}
+
+	keys := make([]string, 0, len(periods))
+	for key := range periods {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "") != (keys[j] == "") {
+			return keys[i] != ""
+		}
+		return keys[i] < keys[j]
+	})
+
+	get := func(teamID string) GlickoResult {
+		if r, ok := ratings[teamID]; ok {
+			return r
+		}
+		return GlickoResult{
+			Rating:     cfg.InitialRating,
+			Deviation:  cfg.InitialDeviation,
+			Volatility: cfg.InitialVolatility,
+		}
+	}
+
+	for _, key := range keys {
+		games := make(map[string][]glickoGame)
+		for _, m := range periods[key] {
+			home := get(m.HomeTeamID)
+			away := get(m.AwayTeamID)
+			ratings[m.HomeTeamID] = home
+			ratings[m.AwayTeamID] = away
+
+			homeScore := 0.5
+			switch {
+			case m.HomeScore > m.AwayScore:
+				homeScore = 1
+			case m.HomeScore < m.AwayScore:
+				homeScore = 0
+			}
+
+			homeMu, homePhi := toGlicko2(home, cfg.InitialRating)
+			awayMu, awayPhi := toGlicko2(away, cfg.InitialRating)
+			games[m.HomeTeamID] = append(games[m.HomeTeamID], glickoGame{opponentMu: awayMu, opponentPhi: awayPhi, score: homeScore})
+			games[m.AwayTeamID] = append(games[m.AwayTeamID], glickoGame{opponentMu: homeMu, opponentPhi: homePhi, score: 1 - homeScore})
+		}
+
+		next := make(map[string]GlickoResult, len(ratings))
+		for teamID, current := range ratings {
+			next[teamID] = updateGlicko(current, games[teamID], cfg)
+		}
+		ratings = next
+	}
+
+	return ratings
+}
+
+// GlickoExpected returns the expected score of a against b, accounting for the
+// rating deviation of both teams.
+func GlickoExpected(a, b GlickoResult, initialRating float64) float64 {
+	muA, phiA := toGlicko2(a, initialRating)
+	muB, phiB := toGlicko2(b, initialRating)
+	return glickoE(muA, muB, math.Sqrt(phiA*phiA+phiB*phiB))
+}
+
+func updateGlicko(current GlickoResult, games []glickoGame, cfg GlickoConfig) GlickoResult {
+	mu, phi := toGlicko2(current, cfg.InitialRating)
+	sigma := current.Volatility
+
+	if len(games) == 0 {
+		phi = math.Sqrt(phi*phi + sigma*sigma)
+		current.Deviation = math.Min(phi*glickoScale, cfg.InitialDeviation)
+		return current
+	}
+
+	var vInv, deltaSum float64
+	for _, g := range games {
+		gPhi := glickoG(g.opponentPhi)
+		e := glickoE(mu, g.opponentMu, g.opponentPhi)
+		vInv += gPhi * gPhi * e * (1 - e)
+		deltaSum += gPhi * (g.score - e)
+	}
+	v := 1 / vInv
+	delta := v * deltaSum
+
+	sigma = glickoVolatility(phi, sigma, v, delta, cfg.Tau)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * deltaSum
+
+	return GlickoResult{
+		Rating:     mu*glickoScale + cfg.InitialRating,
+		Deviation:  phi * glickoScale,
+		Volatility: sigma,
+		Games:      current.Games + len(games),
+	}
+}
+
+// glickoVolatility solves for the new volatility using the Illinois variant of
+// regula falsi, as described in step 5 of the Glicko-2 paper.
+func glickoVolatility(phi, sigma, v, delta, tau float64) float64 {
+	const epsilon = 1e-6
+
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	lower := a
+	var upper float64
+	if delta*delta > phi*phi+v {
+		upper = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		upper = a - k*tau
+	}
+
+	fLower := f(lower)
+	fUpper := f(upper)
+	for math.Abs(upper-lower) > epsilon {
+		c := lower + (lower-upper)*fLower/(fUpper-fLower)
+		fC := f(c)
+		if fC*fUpper <= 0 {
+			lower = upper
+			fLower = fUpper
+		} else {
+			fLower /= 2
+		}
+		upper = c
+		fUpper = fC
+	}
+
+	return math.Exp(lower / 2)
+}
+
+func toGlicko2(r GlickoResult, initialRating float64) (mu, phi float64) {
+	return (r.Rating - initialRating) / glickoScale, r.Deviation / glickoScale
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoE(mu, opponentMu, opponentPhi float64) float64 {
+	return 1 / (1 + math.Exp(-glickoG(opponentPhi)*(mu-opponentMu)))
+}
+
+// glickoPeriodKey returns the rating period of a match played on date in one
+// scheme, so keys sort in play order: ISO weeks, or zero-padded matchdays.
+// Matches the scheme cannot place get "".
+func glickoPeriodKey(m model.MatchResult, date string, period GlickoPeriod) string {
+	if period == GlickoPeriodMatchday {
+		if n, err := strconv.Atoi(m.MatchdayTag); err == nil {
+			return fmt.Sprintf("md-%03d", n)
+		}
+		return ""
+	}
+	if d, err := time.Parse("2006-01-02", date); err == nil {
+		year, w := d.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, w)
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package power
+
+import (
+	"math"
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// GoalModelOptions configures FitGoalModel.
+type GoalModelOptions struct {
+	// Ridge pulls every rating towards zero. It keeps ratings finite for teams
+	// with few games and anchors groups that never meet each other.
+	Ridge float64
+	// Margin dampens lopsided results before fitting.
+	Margin MarginConfig
+	// Iterations bounds the number of Gauss-Seidel sweeps.
+	Iterations int
+	// FitHomeAdvantage adds a shared home term for non-neutral matches.
+	FitHomeAdvantage bool
+}
+
+// DefaultGoalModelOptions returns sensible defaults for youth league data.
+func DefaultGoalModelOptions() GoalModelOptions {
+	return GoalModelOptions{Ridge: 1, Margin: DefaultMarginConfig(), Iterations: 500, FitHomeAdvantage: true}
+}
+
+// GoalModel is a least-squares (Massey-style) rating model: the expected goal
+// difference of a match is rating(home) - rating(away), plus HomeAdvantage
+// unless the match is neutral.
+type GoalModel struct {
+	Ratings map[string]float64
+	Games   map[string]int
+	// HomeAdvantage is the extra goal difference of the home side.
+	HomeAdvantage float64
+	// Sigma is the residual standard deviation of the goal difference.
+	Sigma   float64
+	Matches int
+	// HomeMatches counts the non-neutral matches the home term was fitted on.
+	HomeMatches int
+}
+
+// FitGoalModel fits team ratings to the goal differences of played matches.
+func FitGoalModel(matches []model.MatchResult, opts GoalModelOptions) GoalModel {
+	// side is +1 for the home team, -1 for the away team and 0 for neutral
+	// matches; it scales the home term in the team's view of the match.
+	type edge struct {
+		opponent string
+		diff     float64
+		side     float64
+	}
+
+	edges := make(map[string][]edge)
+	played := make([]model.MatchResult, 0, len(matches))
+	homeMatches := 0
+	for _, m := range matches {
+		if !m.Played() || m.HomeTeamID == "" || m.AwayTeamID == "" || m.HomeTeamID == m.AwayTeamID {
+			continue
+		}
+		side := 0.0
+		if opts.FitHomeAdvantage && !m.Neutral {
+			side = 1
+			homeMatches++
+		}
+		home, away := opts.Margin.Goals(m.HomeScore, m.AwayScore)
+		edges[m.HomeTeamID] = append(edges[m.HomeTeamID], edge{opponent: m.AwayTeamID, diff: home - away, side: side})
+		edges[m.AwayTeamID] = append(edges[m.AwayTeamID], edge{opponent: m.HomeTeamID, diff: away - home, side: -side})
+		played = append(played, m)
+	}
+
+	gm := GoalModel{
+		Ratings:     make(map[string]float64, len(edges)),
+		Games:       make(map[string]int, len(edges)),
+		Matches:     len(played),
+		HomeMatches: homeMatches,
+	}
+	ids := make([]string, 0, len(edges))
+	for id, es := range edges {
+		ids = append(ids, id)
+		gm.Ratings[id] = 0
+		gm.Games[id] = len(es)
+	}
+	sort.Strings(ids)
+
+	iterations := opts.Iterations
+	if iterations <= 0 {
+		iterations = DefaultGoalModelOptions().Iterations
+	}
+	for iter := 0; iter < iterations; iter++ {
+		maxChange := 0.0
+		for _, id := range ids {
+			sum := 0.0
+			for _, e := range edges[id] {
+				sum += e.diff - e.side*gm.HomeAdvantage + gm.Ratings[e.opponent]
+			}
+			next := sum / (float64(len(edges[id])) + opts.Ridge)
+			maxChange = math.Max(maxChange, math.Abs(next-gm.Ratings[id]))
+			gm.Ratings[id] = next
+		}
+		if homeMatches > 0 {
+			sum := 0.0
+			for _, id := range ids {
+				for _, e := range edges[id] {
+					if e.side > 0 {
+						sum += e.diff - (gm.Ratings[id] - gm.Ratings[e.opponent])
+					}
+				}
+			}
+			next := sum / float64(homeMatches)
+			maxChange = math.Max(maxChange, math.Abs(next-gm.HomeAdvantage))
+			gm.HomeAdvantage = next
+		}
+		if maxChange < 1e-9 {
+			break
+		}
+	}
+
+	if len(played) > 0 {
+		sq := 0.0
+		for _, m := range played {
+			home, away := opts.Margin.Goals(m.HomeScore, m.AwayScore)
+			resid := (home - away) - gm.Predict(m)
+			sq += resid * resid
+		}
+		gm.Sigma = math.Sqrt(sq / float64(len(played)))
+	}
+
+	return gm
+}
+
+// Rating returns the fitted rating of a team, 0 for unknown teams.
+func (g GoalModel) Rating(teamID string) float64 {
+	return g.Ratings[teamID]
+}
+
+// PredictDiff returns the expected goal difference from the home team's view
+// at the home team's venue.
+func (g GoalModel) PredictDiff(homeTeamID, awayTeamID string) float64 {
+	return g.Ratings[homeTeamID] - g.Ratings[awayTeamID] + g.HomeAdvantage
+}
+
+// Predict returns the expected goal difference of m from the home team's view,
+// leaving out the home term for neutral matches.
+func (g GoalModel) Predict(m model.MatchResult) float64 {
+	diff := g.Ratings[m.HomeTeamID] - g.Ratings[m.AwayTeamID]
+	if !m.Neutral {
+		diff += g.HomeAdvantage
+	}
+	return diff
+}
@@ -0,0 +1,101 @@
+package power
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// HomeAdvantage summarizes how much playing at home is worth in a set of
+// matches. Neutral matches are counted but not used for the estimate.
+type HomeAdvantage struct {
+	Matches        int `json:"matches"`
+	NeutralMatches int `json:"neutralMatches"`
+	HomeWins       int `json:"homeWins"`
+	Draws          int `json:"draws"`
+	AwayWins       int `json:"awayWins"`
+	// HomeScore is the home side's share of the points, a draw counting half.
+	HomeScore         float64 `json:"homeScore"`
+	HomeGoalsPerMatch float64 `json:"homeGoalsPerMatch"`
+	AwayGoalsPerMatch float64 `json:"awayGoalsPerMatch"`
+	// Goals is the home edge in goals per match after adjusting for the
+	// strength of the teams involved, with its standard error.
+	Goals       float64 `json:"goals"`
+	GoalsStdErr float64 `json:"goalsStdErr"`
+	// EloPoints is the rating bonus for the home side that reproduces HomeScore.
+	EloPoints float64 `json:"eloPoints"`
+	// Significant reports whether Goals is more than two standard errors away
+	// from zero.
+	Significant bool `json:"significant"`
+}
+
+// EstimateHomeAdvantage estimates the home advantage from played, non-neutral
+// matches.
+func EstimateHomeAdvantage(matches []model.MatchResult) HomeAdvantage {
+	var ha HomeAdvantage
+	homeGoals, awayGoals := 0, 0
+	for _, m := range matches {
+		if !m.Played() || m.HomeTeamID == "" || m.AwayTeamID == "" {
+			continue
+		}
+		if m.Neutral {
+			ha.NeutralMatches++
+			continue
+		}
+		ha.Matches++
+		homeGoals += m.HomeScore
+		awayGoals += m.AwayScore
+		switch {
+		case m.HomeScore > m.AwayScore:
+			ha.HomeWins++
+		case m.HomeScore < m.AwayScore:
+			ha.AwayWins++
+		default:
+			ha.Draws++
+		}
+	}
+	if ha.Matches == 0 {
+		return ha
+	}
+
+	n := float64(ha.Matches)
+	ha.HomeGoalsPerMatch = float64(homeGoals) / n
+	ha.AwayGoalsPerMatch = float64(awayGoals) / n
+	ha.HomeScore = (float64(ha.HomeWins) + 0.5*float64(ha.Draws)) / n
+
+	// Keep the Elo bonus finite for tiny samples where one side won everything.
+	share := math.Min(math.Max(ha.HomeScore, 0.01), 0.99)
+	ha.EloPoints = 400 * math.Log10(share/(1-share))
+
+	fit := FitGoalModel(matches, DefaultGoalModelOptions())
+	ha.Goals = fit.HomeAdvantage
+	if fit.HomeMatches > 0 {
+		ha.GoalsStdErr = fit.Sigma / math.Sqrt(float64(fit.HomeMatches))
+	}
+	ha.Significant = ha.GoalsStdErr > 0 && math.Abs(ha.Goals) > 2*ha.GoalsStdErr
+	return ha
+}
+
+// ResolveHomeAdvantage turns user input into an Elo home bonus: "" or "auto"
+// uses the estimate from matches if it is significant, "off" disables it and a
+// number is used as is.
+func ResolveHomeAdvantage(value string, matches []model.MatchResult) (float64, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", "auto":
+		ha := EstimateHomeAdvantage(matches)
+		if !ha.Significant {
+			return 0, nil
+		}
+		return ha.EloPoints, nil
+	case "off", "none":
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("homeAdvantage must be auto, off or a number of Elo points")
+	}
+	return v, nil
+}
@@ -0,0 +1,122 @@
+package power
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MarginMode selects how lopsided results are dampened before they feed into
+// the power metrics and Elo.
+type MarginMode string
+
+const (
+	// MarginModeLinear counts every goal of margin (the historic behavior).
+	MarginModeLinear MarginMode = "linear"
+	// MarginModeCap counts the margin up to MarginConfig.Cap goals.
+	MarginModeCap MarginMode = "cap"
+	// MarginModeLog counts log2(1+margin), so 1:0 stays 1 and 15:0 becomes 4.
+	MarginModeLog MarginMode = "log"
+	// MarginModeDiminishing uses a saturating curve that approaches roughly
+	// MarginConfig.Cap goals for very large margins.
+	MarginModeDiminishing MarginMode = "diminishing"
+)
+
+const defaultMarginCap = 5
+
+// MarginConfig describes the margin dampening applied to each match.
+type MarginConfig struct {
+	Mode MarginMode `json:"mode"`
+	Cap  float64    `json:"cap,omitempty"`
+}
+
+// DefaultMarginConfig leaves margins untouched.
+func DefaultMarginConfig() MarginConfig {
+	return MarginConfig{Mode: MarginModeLinear}
+}
+
+// ParseMarginConfig builds a MarginConfig from user input such as query
+// parameters. An empty mode yields the default config.
+func ParseMarginConfig(mode, capValue string) (MarginConfig, error) {
+	cfg := DefaultMarginConfig()
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return cfg, nil
+	}
+
+	switch MarginMode(mode) {
+	case MarginModeLinear:
+		return cfg, nil
+	case MarginModeCap, MarginModeLog, MarginModeDiminishing:
+		cfg.Mode = MarginMode(mode)
+	default:
+		return cfg, fmt.Errorf("unknown margin mode %q", mode)
+	}
+
+	if cfg.Mode == MarginModeLog {
+		return cfg, nil
+	}
+	cfg.Cap = defaultMarginCap
+	if capValue = strings.TrimSpace(capValue); capValue != "" {
+		v, err := strconv.ParseFloat(capValue, 64)
+		if err != nil || v < 1 {
+			return cfg, fmt.Errorf("margin cap must be a number >= 1")
+		}
+		cfg.Cap = v
+	}
+	return cfg, nil
+}
+
+// Linear reports whether the config leaves margins untouched.
+func (c MarginConfig) Linear() bool {
+	return c.Mode == "" || c.Mode == MarginModeLinear
+}
+
+// Dampen maps a non-negative goal margin to the margin that is counted.
+// Every mode keeps a one-goal margin at 1.
+func (c MarginConfig) Dampen(margin float64) float64 {
+	if margin <= 0 {
+		return 0
+	}
+	limit := c.Cap
+	if limit < 1 {
+		limit = defaultMarginCap
+	}
+
+	switch c.Mode {
+	case MarginModeCap:
+		return math.Min(margin, limit)
+	case MarginModeLog:
+		return math.Log2(1 + margin)
+	case MarginModeDiminishing:
+		return (1 - math.Exp(-margin/limit)) / (1 - math.Exp(-1/limit))
+	default:
+		return margin
+	}
+}
+
+// Goals returns the dampened score of a match. The losing side keeps its
+// goals; the winner keeps only the dampened margin on top of them.
+func (c MarginConfig) Goals(homeScore, awayScore int) (home, away float64) {
+	home, away = float64(homeScore), float64(awayScore)
+	if c.Linear() || homeScore == awayScore {
+		return home, away
+	}
+	if homeScore > awayScore {
+		return away + c.Dampen(home-away), away
+	}
+	return home, home + c.Dampen(away-home)
+}
+
+// String renders the config for logs and response metadata.
+func (c MarginConfig) String() string {
+	switch {
+	case c.Linear():
+		return string(MarginModeLinear)
+	case c.Mode == MarginModeLog:
+		return string(c.Mode)
+	default:
+		return fmt.Sprintf("%s(%g)", c.Mode, c.Cap)
+	}
+}
@@ -6,64 +6,170 @@ import (
 	"github.com/schlubbi/score_board/internal/model"
 )
 
+// Weights controls how the normalized metrics combine into the PowerScore.
+type Weights struct {
+	Offense   float64 `json:"offense"`
+	Defense   float64 `json:"defense"`
+	Dominance float64 `json:"dominance"`
+}
+
+// DefaultWeights returns the weights used by ComputeMetrics.
+func DefaultWeights() Weights {
+	return Weights{Offense: 0.4, Defense: 0.4, Dominance: 0.2}
+}
+
+// Score combines a normalized metric set into a single power score.
+func (w Weights) Score(n model.NormalizedSet) float64 {
+	return w.Offense*n.Offense + w.Defense*n.Defense + w.Dominance*n.Dominance
+}
+
+// MetricOptions tunes how ComputeMetricsWithOptions derives the metrics.
+type MetricOptions struct {
+	Formula   Formula
+	Margin    MarginConfig
+	Shrinkage ShrinkageConfig
+}
+
+// DefaultMetricOptions returns the options used by ComputeMetrics.
+func DefaultMetricOptions() MetricOptions {
+	return MetricOptions{Formula: DefaultFormula(), Margin: DefaultMarginConfig(), Shrinkage: DefaultShrinkageConfig()}
+}
+
 // ComputeMetrics returns power metrics normalized within the provided slice.
 func ComputeMetrics(teams []model.TeamStats) map[string]model.MetricSet {
+	return ComputeMetricsWithOptions(teams, nil, DefaultMetricOptions())
+}
+
+// ComputeMetricsWithOptions returns power metrics normalized within the provided
+// slice using opts.Formula. When opts.Margin dampens margins, goals are
+// re-aggregated from the supplied matches; teams without matches fall back to
+// their table totals. When opts.Shrinkage is enabled, per-game rates are pulled
+// towards the pool mean and credible intervals are reported.
+func ComputeMetricsWithOptions(teams []model.TeamStats, matches []model.MatchResult, opts MetricOptions) map[string]model.MetricSet {
 	metrics := make(map[string]model.MetricSet, len(teams))
 	if len(teams) == 0 {
 		return metrics
 	}
 
+	var dampened map[string]teamTotals
+	if !opts.Margin.Linear() {
+		dampened = dampenedTotals(matches, opts.Margin)
+	}
+
+	totals := make([]teamTotals, len(teams))
+	for i, team := range teams {
+		t, ok := dampened[team.TeamID]
+		if !ok {
+			t = tableTotals(team)
+		}
+		totals[i] = t
+	}
+
+	var shrunk []shrunkRates
+	if opts.Shrinkage.Enabled() {
+		shrunk = shrinkTotals(teams, totals, opts.Shrinkage)
+	}
+
 	offenses := make([]float64, len(teams))
 	defenses := make([]float64, len(teams))
 	dominances := make([]float64, len(teams))
 	valid := make([]bool, len(teams))
 
-	for i, team := range teams {
-		if team.Games <= 0 {
+	for i := range teams {
+		if totals[i].games <= 0 {
 			valid[i] = false
 			continue
 		}
 		valid[i] = true
-		offense, defense, dominance := calculateRaw(team)
+		if shrunk != nil {
+			offenses[i] = shrunk[i].goalsFor
+			defenses[i] = 1 - shrunk[i].goalsAgainst
+			dominances[i] = shrunk[i].goalsFor - shrunk[i].goalsAgainst
+			continue
+		}
+		offense, defense, dominance := calculateRaw(totals[i])
 		offenses[i] = offense
 		defenses[i] = defense
 		dominances[i] = dominance
 	}
 
-	offenseNorm := normalize(offenses, valid)
-	defenseNorm := normalize(defenses, valid)
-	dominanceNorm := normalize(dominances, valid)
+	offenseNorm := opts.Formula.normalize(offenses, valid)
+	defenseNorm := opts.Formula.normalize(defenses, valid)
+	dominanceNorm := opts.Formula.normalize(dominances, valid)
 
 	for i, team := range teams {
+		normalized := model.NormalizedSet{
+			Offense:   offenseNorm[i],
+			Defense:   defenseNorm[i],
+			Dominance: dominanceNorm[i],
+		}
 		score := 0.0
 		if valid[i] {
-			score = 0.4*offenseNorm[i] + 0.4*defenseNorm[i] + 0.2*dominanceNorm[i]
+			score = opts.Formula.Weights.Score(normalized)
 		}
-		metrics[team.TeamID] = model.MetricSet{
-			Offense:   offenses[i],
-			Defense:   defenses[i],
-			Dominance: dominances[i],
-			Normalized: model.NormalizedSet{
-				Offense:   offenseNorm[i],
-				Defense:   defenseNorm[i],
-				Dominance: dominanceNorm[i],
-			},
+		set := model.MetricSet{
+			Offense:    offenses[i],
+			Defense:    defenses[i],
+			Dominance:  dominances[i],
+			Normalized: normalized,
 			PowerScore: score,
+			LowSample:  totals[i].games < LowSampleGames,
 		}
+		if shrunk != nil && valid[i] {
+			intervals := shrunk[i].intervals
+			set.Intervals = &intervals
+		}
+		metrics[team.TeamID] = set
 	}
 
 	return metrics
 }
 
-func calculateRaw(team model.TeamStats) (offense, defense, dominance float64) {
-	if team.Games == 0 {
+type teamTotals struct {
+	games        float64
+	goalsFor     float64
+	goalsAgainst float64
+}
+
+func tableTotals(team model.TeamStats) teamTotals {
+	return teamTotals{
+		games:        float64(team.Games),
+		goalsFor:     float64(team.GoalsFor),
+		goalsAgainst: float64(team.GoalsAgainst),
+	}
+}
+
+func dampenedTotals(matches []model.MatchResult, margin MarginConfig) map[string]teamTotals {
+	totals := make(map[string]teamTotals)
+	for _, m := range matches {
+		if !m.Played() || m.HomeTeamID == "" || m.AwayTeamID == "" {
+			continue
+		}
+		homeGoals, awayGoals := margin.Goals(m.HomeScore, m.AwayScore)
+
+		home := totals[m.HomeTeamID]
+		home.games++
+		home.goalsFor += homeGoals
+		home.goalsAgainst += awayGoals
+		totals[m.HomeTeamID] = home
+
+		away := totals[m.AwayTeamID]
+		away.games++
+		away.goalsFor += awayGoals
+		away.goalsAgainst += homeGoals
+		totals[m.AwayTeamID] = away
+	}
+	return totals
+}
+
+func calculateRaw(totals teamTotals) (offense, defense, dominance float64) {
+	if totals.games == 0 {
 		return 0, 0, 0
 	}
 
-	games := float64(team.Games)
-	offense = float64(team.GoalsFor) / games
-	defense = 1 - (float64(team.GoalsAgainst) / games)
-	dominance = float64(team.GoalsFor-team.GoalsAgainst) / games
+	offense = totals.goalsFor / totals.games
+	defense = 1 - (totals.goalsAgainst / totals.games)
+	dominance = (totals.goalsFor - totals.goalsAgainst) / totals.games
 	return offense, defense, dominance
 }
 
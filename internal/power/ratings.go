@@ -0,0 +1,95 @@
+package power
+
+import "github.com/schlubbi/score_board/internal/model"
+
+// RatingInput bundles the data and parameters used by RateAll.
+type RatingInput struct {
+	LeagueTeams   []model.TeamStats
+	LeagueMatches []model.MatchResult
+	IndoorTeams   []model.TeamStats
+	IndoorMatches []model.MatchResult
+	Metrics       MetricOptions
+	Elo           EloParams
+	Glicko        GlickoConfig
+	Model         GoalModelOptions
+	Form          FormOptions
+}
+
+// TeamRatings is one team rated by every available method.
+type TeamRatings struct {
+	GroupMetrics   model.MetricSet `json:"groupMetrics"`
+	OverallMetrics model.MetricSet `json:"overallMetrics"`
+	Elo            float64         `json:"elo"`
+	EloGames       int             `json:"eloGames"`
+	Glicko         float64         `json:"glicko"`
+	GlickoRD       float64         `json:"glickoDeviation"`
+	// Calibrated is the goal-difference rating on the scale shared by all
+	// groups, see Calibrate.
+	Calibrated  float64     `json:"calibrated"`
+	GroupOffset float64     `json:"groupOffset"`
+	Form        *model.Form `json:"form,omitempty"`
+}
+
+// RateAll rates every league team with the power metrics, Elo, Glicko-2, the
+// calibrated goal model and form. Elo and Glicko use league matches only.
+func RateAll(in RatingInput) map[string]TeamRatings {
+	matches := append([]model.MatchResult(nil), in.LeagueMatches...)
+	model.SortChronologically(matches)
+
+	overall := ComputeMetricsWithOptions(in.LeagueTeams, matches, in.Metrics)
+	groupTeams := make(map[string][]model.TeamStats)
+	groupMatches := make(map[string][]model.MatchResult)
+	for _, team := range in.LeagueTeams {
+		groupTeams[team.GroupID] = append(groupTeams[team.GroupID], team)
+	}
+	for _, m := range matches {
+		groupMatches[m.GroupID] = append(groupMatches[m.GroupID], m)
+	}
+	groupMetrics := make(map[string]model.MetricSet, len(in.LeagueTeams))
+	for groupID, teams := range groupTeams {
+		for id, set := range ComputeMetricsWithOptions(teams, groupMatches[groupID], in.Metrics) {
+			groupMetrics[id] = set
+		}
+	}
+
+	elo, _ := ComputeEloWithParams(matches, in.Elo)
+	glicko := ComputeGlicko(matches, in.Glicko)
+	forms := ComputeForm(matches, in.Form)
+	calibrated := Calibrate(CalibrationInput{
+		LeagueTeams:   in.LeagueTeams,
+		LeagueMatches: matches,
+		IndoorTeams:   in.IndoorTeams,
+		IndoorMatches: in.IndoorMatches,
+		Model:         in.Model,
+	})
+	calibratedByID := make(map[string]CalibratedTeam, len(calibrated.Teams))
+	for _, team := range calibrated.Teams {
+		calibratedByID[team.Team.TeamID] = team
+	}
+
+	out := make(map[string]TeamRatings, len(in.LeagueTeams))
+	for _, team := range in.LeagueTeams {
+		ratings := TeamRatings{
+			GroupMetrics:   groupMetrics[team.TeamID],
+			OverallMetrics: overall[team.TeamID],
+			Elo:            in.Elo.InitialRating,
+			Glicko:         in.Glicko.InitialRating,
+			GlickoRD:       in.Glicko.InitialDeviation,
+			Calibrated:     calibratedByID[team.TeamID].Rating,
+			GroupOffset:    calibratedByID[team.TeamID].GroupOffset,
+		}
+		if res, ok := elo[team.TeamID]; ok {
+			ratings.Elo = res.Rating
+			ratings.EloGames = res.Games
+		}
+		if res, ok := glicko[team.TeamID]; ok {
+			ratings.Glicko = res.Rating
+			ratings.GlickoRD = res.Deviation
+		}
+		if form, ok := forms[team.TeamID]; ok {
+			ratings.Form = &form
+		}
+		out[team.TeamID] = ratings
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package power
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// LowSampleGames is the number of games below which a team's metrics are
+// flagged as unreliable.
+const LowSampleGames = 4
+
+// credibleZ is the standard normal quantile for a 90% credible interval.
+const credibleZ = 1.6449
+
+// ShrinkageScope selects the pool whose mean a team's rates are pulled towards.
+type ShrinkageScope string
+
+const (
+	// ShrinkageNone disables shrinkage.
+	ShrinkageNone ShrinkageScope = "none"
+	// ShrinkageGroup shrinks towards the mean of the team's own group.
+	ShrinkageGroup ShrinkageScope = "group"
+	// ShrinkageLeague shrinks towards the mean of all supplied teams.
+	ShrinkageLeague ShrinkageScope = "league"
+)
+
+// ShrinkageConfig configures the empirical-Bayes shrinkage of per-game rates.
+type ShrinkageConfig struct {
+	Scope ShrinkageScope `json:"scope"`
+	// PriorGames is the weight of the pool mean, expressed in games. Zero
+	// estimates it from the spread of the data.
+	PriorGames float64 `json:"priorGames,omitempty"`
+}
+
+// DefaultShrinkageConfig disables shrinkage.
+func DefaultShrinkageConfig() ShrinkageConfig {
+	return ShrinkageConfig{Scope: ShrinkageNone}
+}
+
+// ParseShrinkageConfig builds a ShrinkageConfig from user input.
+func ParseShrinkageConfig(scope, priorGames string) (ShrinkageConfig, error) {
+	cfg := DefaultShrinkageConfig()
+	switch s := ShrinkageScope(strings.ToLower(strings.TrimSpace(scope))); s {
+	case "", ShrinkageNone:
+		return cfg, nil
+	case ShrinkageGroup, ShrinkageLeague:
+		cfg.Scope = s
+	default:
+		return cfg, fmt.Errorf("unknown shrinkage scope %q", scope)
+	}
+
+	if priorGames = strings.TrimSpace(priorGames); priorGames != "" {
+		v, err := strconv.ParseFloat(priorGames, 64)
+		if err != nil || v < 0 {
+			return cfg, fmt.Errorf("priorGames must be a non-negative number")
+		}
+		cfg.PriorGames = v
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether shrinkage is switched on.
+func (c ShrinkageConfig) Enabled() bool {
+	return c.Scope == ShrinkageGroup || c.Scope == ShrinkageLeague
+}
+
+// ratePrior is a Gamma prior on a per-game scoring rate, expressed as the pool
+// mean and its weight in games.
+type ratePrior struct {
+	mean   float64
+	weight float64
+}
+
+// posterior returns the posterior mean and variance of a rate given the goals
+// and games observed (Gamma-Poisson conjugate update).
+func (p ratePrior) posterior(goals, games float64) (mean, variance float64) {
+	shape := p.mean*p.weight + goals
+	rate := p.weight + games
+	if rate == 0 {
+		return 0, 0
+	}
+	return shape / rate, shape / (rate * rate)
+}
+
+// interval returns the 90% credible interval of the posterior rate using the
+// Wilson-Hilferty approximation of the Gamma quantiles.
+func (p ratePrior) interval(goals, games float64) model.Interval {
+	shape := p.mean*p.weight + goals
+	rate := p.weight + games
+	if shape <= 0 || rate <= 0 {
+		return model.Interval{}
+	}
+	quantile := func(z float64) float64 {
+		c := 1 / (9 * shape)
+		v := 1 - c + z*math.Sqrt(c)
+		if v < 0 {
+			return 0
+		}
+		return shape / rate * v * v * v
+	}
+	return model.Interval{Low: quantile(-credibleZ), High: quantile(credibleZ)}
+}
+
+// estimatePrior derives the pool mean and the prior weight from the observed
+// rates. The weight is the Poisson mean divided by the between-team variance
+// that remains after removing the expected sampling noise.
+func estimatePrior(goals, games []float64, fixedWeight float64) ratePrior {
+	totalGoals, totalGames := 0.0, 0.0
+	n := 0
+	for i := range goals {
+		if games[i] <= 0 {
+			continue
+		}
+		totalGoals += goals[i]
+		totalGames += games[i]
+		n++
+	}
+	if totalGames == 0 {
+		return ratePrior{}
+	}
+	mean := totalGoals / totalGames
+	if fixedWeight > 0 {
+		return ratePrior{mean: mean, weight: fixedWeight}
+	}
+
+	spread, noise := 0.0, 0.0
+	for i := range goals {
+		if games[i] <= 0 {
+			continue
+		}
+		r := goals[i] / games[i]
+		spread += (r - mean) * (r - mean)
+		noise += mean / games[i]
+	}
+	spread /= float64(n)
+	noise /= float64(n)
+
+	// Bound the weight so a lucky pool neither disables shrinkage entirely nor
+	// flattens every team to the mean.
+	const minWeight, maxWeight = 0.5, 20.0
+	between := spread - noise
+	if between <= 0 {
+		return ratePrior{mean: mean, weight: maxWeight}
+	}
+	weight := math.Min(math.Max(mean/between, minWeight), maxWeight)
+	return ratePrior{mean: mean, weight: weight}
+}
+
+type shrunkRates struct {
+	goalsFor     float64
+	goalsAgainst float64
+	intervals    model.MetricIntervals
+}
+
+// shrinkTotals applies empirical-Bayes shrinkage to the goals-for and
+// goals-against rates of every team with games, pooling either per group or
+// across all teams.
+func shrinkTotals(teams []model.TeamStats, totals []teamTotals, cfg ShrinkageConfig) []shrunkRates {
+	pools := make(map[string][]int)
+	for i, team := range teams {
+		key := ""
+		if cfg.Scope == ShrinkageGroup {
+			key = team.GroupID
+		}
+		pools[key] = append(pools[key], i)
+	}
+
+	out := make([]shrunkRates, len(teams))
+	for _, idx := range pools {
+		goalsFor := make([]float64, len(idx))
+		goalsAgainst := make([]float64, len(idx))
+		games := make([]float64, len(idx))
+		for k, i := range idx {
+			goalsFor[k] = totals[i].goalsFor
+			goalsAgainst[k] = totals[i].goalsAgainst
+			games[k] = totals[i].games
+		}
+		forPrior := estimatePrior(goalsFor, games, cfg.PriorGames)
+		againstPrior := estimatePrior(goalsAgainst, games, cfg.PriorGames)
+
+		for _, i := range idx {
+			t := totals[i]
+			if t.games <= 0 {
+				continue
+			}
+			gf, gfVar := forPrior.posterior(t.goalsFor, t.games)
+			ga, gaVar := againstPrior.posterior(t.goalsAgainst, t.games)
+			gfInt := forPrior.interval(t.goalsFor, t.games)
+			gaInt := againstPrior.interval(t.goalsAgainst, t.games)
+			spread := credibleZ * math.Sqrt(gfVar+gaVar)
+			out[i] = shrunkRates{
+				goalsFor:     gf,
+				goalsAgainst: ga,
+				intervals: model.MetricIntervals{
+					Offense:   gfInt,
+					Defense:   model.Interval{Low: 1 - gaInt.High, High: 1 - gaInt.Low},
+					Dominance: model.Interval{Low: gf - ga - spread, High: gf - ga + spread},
+				},
+			}
+		}
+	}
+	return out
+}
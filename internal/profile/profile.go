@@ -0,0 +1,125 @@
+package profile
+
+import (
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/history"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// Competition names used in Appearance.
+const (
+	CompetitionLeague = "league"
+	CompetitionIndoor = "indoor"
+)
+
+// Appearance is the team's table row in one group.
+type Appearance struct {
+	Competition string          `json:"competition"`
+	Team        model.TeamStats `json:"team"`
+}
+
+// Profile aggregates everything known about a team across groups and
+// competitions.
+type Profile struct {
+	TeamID  string `json:"teamId"`
+	Name    string `json:"name"`
+	LogoURL string `json:"logoUrl,omitempty"`
+	Club    string `json:"club"`
+	// IndoorTeamIDs lists the indoor tournament IDs matched to this team.
+	IndoorTeamIDs []string            `json:"indoorTeamIds,omitempty"`
+	Groups        []Appearance        `json:"groups"`
+	Matches       []model.MatchResult `json:"matches"`
+	Ratings       *power.TeamRatings  `json:"ratings,omitempty"`
+	// RankHistory is left out until the matches have periods (dates or
+	// matchdays), see history.Periods.
+	RankHistory []history.Entry `json:"rankHistory,omitempty"`
+	Form        *model.Form     `json:"form,omitempty"`
+}
+
+// Input holds the data a profile is assembled from. Ratings and History are
+// keyed by league team ID, see power.RateAll and history.Build.
+type Input struct {
+	League  []model.GroupSnapshot
+	Indoor  []model.GroupSnapshot
+	Ratings map[string]power.TeamRatings
+	History map[string][]history.Entry
+	Form    power.FormOptions
+}
+
+// Build assembles the profile of teamID, which may be a league or an indoor
+// team ID. Indoor teams matched to a league team share its profile.
+func Build(in Input, teamID string) (Profile, bool) {
+	var leagueTeams, indoorTeams []model.TeamStats
+	for _, snap := range in.League {
+		leagueTeams = append(leagueTeams, snap.Teams...)
+	}
+	for _, snap := range in.Indoor {
+		indoorTeams = append(indoorTeams, snap.Teams...)
+	}
+	mapping, _, _ := power.MatchIndoorTeams(leagueTeams, indoorTeams)
+	if id, ok := mapping[teamID]; ok {
+		teamID = id
+	}
+
+	// ids holds every ID the team is known under.
+	ids := map[string]struct{}{teamID: {}}
+	p := Profile{TeamID: teamID, Groups: []Appearance{}, Matches: []model.MatchResult{}}
+	for indoorID, leagueID := range mapping {
+		if leagueID == teamID && indoorID != teamID {
+			ids[indoorID] = struct{}{}
+			p.IndoorTeamIDs = append(p.IndoorTeamIDs, indoorID)
+		}
+	}
+	sort.Strings(p.IndoorTeamIDs)
+
+	collect := func(snaps []model.GroupSnapshot, competition string) {
+		for _, snap := range snaps {
+			for _, team := range snap.Teams {
+				if _, ok := ids[team.TeamID]; ok {
+					p.Groups = append(p.Groups, Appearance{Competition: competition, Team: team})
+				}
+			}
+			for _, m := range snap.Matches {
+				_, home := ids[m.HomeTeamID]
+				_, away := ids[m.AwayTeamID]
+				if home || away {
+					p.Matches = append(p.Matches, m)
+				}
+			}
+		}
+	}
+	collect(in.League, CompetitionLeague)
+	collect(in.Indoor, CompetitionIndoor)
+	if len(p.Groups) == 0 {
+		return Profile{}, false
+	}
+	model.SortChronologically(p.Matches)
+
+	// Prefer the league spelling and logo; indoor rows fill the gaps.
+	for _, appearance := range p.Groups {
+		if p.Name == "" {
+			p.Name = appearance.Team.TeamName
+		}
+		if p.LogoURL == "" {
+			p.LogoURL = appearance.Team.LogoURL
+		}
+	}
+	p.Club = model.ClubName(p.Name)
+
+	if ratings, ok := in.Ratings[teamID]; ok {
+		p.Ratings = &ratings
+	}
+	if entries, ok := in.History[teamID]; ok {
+		p.RankHistory = entries
+	}
+
+	// Form spans league and indoor matches under the league ID.
+	translated := power.TranslateMatches(p.Matches, mapping)
+	if form, ok := power.ComputeForm(translated, in.Form)[teamID]; ok {
+		p.Form = &form
+	}
+
+	return p, true
+}
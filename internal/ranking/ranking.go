@@ -0,0 +1,349 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/history"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// Ranking methods.
+const (
+	MethodPower      = "power"
+	MethodElo        = "elo"
+	MethodGlicko     = "glicko"
+	MethodCalibrated = "calibrated"
+	MethodForm       = "form"
+)
+
+// Methods lists the available ranking methods.
+func Methods() []string {
+	return []string{MethodPower, MethodElo, MethodGlicko, MethodCalibrated, MethodForm}
+}
+
+// ParseMethod validates a method name; empty selects power.
+func ParseMethod(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return MethodPower, nil
+	}
+	for _, method := range Methods() {
+		if value == method {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("unknown ranking method %q (want %s)", value, strings.Join(Methods(), ", "))
+}
+
+// Entry is one team's place in a ranking. Details holds the method-specific
+// values behind the score.
+type Entry struct {
+	Rank    int             `json:"rank"`
+	Team    model.TeamStats `json:"team"`
+	Score   float64         `json:"score"`
+	Details any             `json:"details"`
+	// Movement is set by AttachMovements.
+	Movement *model.Movement `json:"movement,omitempty"`
+}
+
+// Ranking orders all teams by one method's score, best first.
+type Ranking struct {
+	Method string  `json:"method"`
+	Teams  []Entry `json:"teams"`
+}
+
+// EloDetails are the details of the elo method.
+type EloDetails struct {
+	Games int `json:"games"`
+}
+
+// GlickoDetails are the details of the glicko method; Low and High span two
+// rating deviations.
+type GlickoDetails struct {
+	Deviation float64 `json:"deviation"`
+	Low       float64 `json:"low"`
+	High      float64 `json:"high"`
+}
+
+// CalibratedDetails are the details of the calibrated method.
+type CalibratedDetails struct {
+	GroupOffset float64 `json:"groupOffset"`
+}
+
+// Build ranks teams by the given method using the ratings from
+// power.RateAll. Teams without games rank last, ties are broken by goal
+// difference, points and name.
+func Build(teams []model.TeamStats, ratings map[string]power.TeamRatings, method string) (Ranking, error) {
+	method, err := ParseMethod(method)
+	if err != nil {
+		return Ranking{}, err
+	}
+
+	entries := make([]Entry, 0, len(teams))
+	for _, team := range teams {
+		r := ratings[team.TeamID]
+		entry := Entry{Team: team}
+		switch method {
+		case MethodPower:
+			entry.Score = r.OverallMetrics.PowerScore
+			entry.Details = r.OverallMetrics
+		case MethodElo:
+			entry.Score = r.Elo
+			entry.Details = EloDetails{Games: r.EloGames}
+		case MethodGlicko:
+			entry.Score = r.Glicko
+			entry.Details = GlickoDetails{Deviation: r.GlickoRD, Low: r.Glicko - 2*r.GlickoRD, High: r.Glicko + 2*r.GlickoRD}
+		case MethodCalibrated:
+			entry.Score = r.Calibrated
+			entry.Details = CalibratedDetails{GroupOffset: r.GroupOffset}
+		case MethodForm:
+			if r.Form != nil {
+				entry.Score = r.Form.Points
+			}
+			entry.Details = r.Form
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].Team.Games > 0) != (entries[j].Team.Games > 0) {
+			return entries[i].Team.Games > 0
+		}
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if entries[i].Team.GoalDiff != entries[j].Team.GoalDiff {
+			return entries[i].Team.GoalDiff > entries[j].Team.GoalDiff
+		}
+		if entries[i].Team.Points != entries[j].Team.Points {
+			return entries[i].Team.Points > entries[j].Team.Points
+		}
+		return entries[i].Team.TeamName < entries[j].Team.TeamName
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return Ranking{Method: method, Teams: entries}, nil
+}
+
+// Movements ranks the teams by method as they stood before the latest period
+// of league matches and now, and returns each team's movement. Indoor matches
+// are cut at the same period. Without periods there is no movement.
+func Movements(in power.RatingInput, method string) (map[string]model.Movement, error) {
+	period := history.LatestPeriod(in.LeagueMatches)
+	if period == "" {
+		return map[string]model.Movement{}, nil
+	}
+	standings := func(until string) (map[string]history.Standing, error) {
+		replayed := in
+		replayed.LeagueTeams, replayed.LeagueMatches = history.Replay(in.LeagueTeams, in.LeagueMatches, until)
+		replayed.IndoorTeams, replayed.IndoorMatches = history.Replay(in.IndoorTeams, in.IndoorMatches, until)
+		result, err := Build(replayed.LeagueTeams, power.RateAll(replayed), method)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]history.Standing, len(result.Teams))
+		for _, entry := range result.Teams {
+			out[entry.Team.TeamID] = history.Standing{Rank: entry.Rank, Score: entry.Score, Points: entry.Team.Points}
+		}
+		return out, nil
+	}
+
+	now, err := standings("")
+	if err != nil {
+		return nil, err
+	}
+	before, err := standings(period)
+	if err != nil {
+		return nil, err
+	}
+	return history.Movements(now, before, period, history.PreviousPeriod(in.LeagueMatches, period)), nil
+}
+
+// AttachMovements sets the movement of every ranked team that has one.
+func AttachMovements(r Ranking, movements map[string]model.Movement) {
+	for i := range r.Teams {
+		if movement, ok := movements[r.Teams[i].Team.TeamID]; ok {
+			r.Teams[i].Movement = &movement
+		}
+	}
+}
+
+// Disagreement compares one team's place in two rankings. Delta is RankB
+// minus RankA, so a positive delta means B ranks the team lower.
+type Disagreement struct {
+	Team   model.TeamStats `json:"team"`
+	RankA  int             `json:"rankA"`
+	RankB  int             `json:"rankB"`
+	Delta  int             `json:"delta"`
+	ScoreA float64         `json:"scoreA"`
+	ScoreB float64         `json:"scoreB"`
+}
+
+// Correlation measures how well two rankings agree, from -1 (reversed) to 1
+// (identical). Both coefficients are computed on the scores, so tied scores
+// count as ties rather than by their tie-broken rank.
+type Correlation struct {
+	Teams    int     `json:"teams"`
+	Spearman float64 `json:"spearman"`
+	Kendall  float64 `json:"kendall"`
+	// MeanAbsDelta is the average rank difference.
+	MeanAbsDelta float64 `json:"meanAbsDelta"`
+}
+
+// Diff lists the rank disagreements between two rankings, largest first.
+type Diff struct {
+	A           string         `json:"a"`
+	B           string         `json:"b"`
+	Correlation Correlation    `json:"correlation"`
+	Teams       []Disagreement `json:"teams"`
+}
+
+// Compare diffs two rankings over the teams present in both. Teams without
+// games are left out, as no method has anything to say about them.
+func Compare(a, b Ranking) Diff {
+	inB := make(map[string]Entry, len(b.Teams))
+	for _, entry := range b.Teams {
+		inB[entry.Team.TeamID] = entry
+	}
+
+	d := Diff{A: a.Method, B: b.Method, Teams: make([]Disagreement, 0, len(a.Teams))}
+	var scoresA, scoresB []float64
+	totalDelta := 0
+	for _, entryA := range a.Teams {
+		entryB, ok := inB[entryA.Team.TeamID]
+		if !ok || entryA.Team.Games == 0 {
+			continue
+		}
+		delta := entryB.Rank - entryA.Rank
+		d.Teams = append(d.Teams, Disagreement{
+			Team:   entryA.Team,
+			RankA:  entryA.Rank,
+			RankB:  entryB.Rank,
+			Delta:  delta,
+			ScoreA: entryA.Score,
+			ScoreB: entryB.Score,
+		})
+		scoresA = append(scoresA, entryA.Score)
+		scoresB = append(scoresB, entryB.Score)
+		totalDelta += abs(delta)
+	}
+	sort.SliceStable(d.Teams, func(i, j int) bool {
+		if abs(d.Teams[i].Delta) != abs(d.Teams[j].Delta) {
+			return abs(d.Teams[i].Delta) > abs(d.Teams[j].Delta)
+		}
+		return d.Teams[i].RankA < d.Teams[j].RankA
+	})
+
+	d.Correlation = Correlation{
+		Teams:    len(d.Teams),
+		Spearman: Spearman(scoresA, scoresB),
+		Kendall:  Kendall(scoresA, scoresB),
+	}
+	if len(d.Teams) > 0 {
+		d.Correlation.MeanAbsDelta = float64(totalDelta) / float64(len(d.Teams))
+	}
+	return d
+}
+
+// Spearman returns the Spearman rank correlation of x and y, with tied values
+// sharing their average rank. It is 0 when either side is constant.
+func Spearman(x, y []float64) float64 {
+	return pearson(averageRanks(x), averageRanks(y))
+}
+
+// Kendall returns Kendall's tau-b of x and y, which corrects for ties. It is
+// 0 when either side is constant.
+func Kendall(x, y []float64) float64 {
+	var concordant, discordant, tiesX, tiesY float64
+	for i := 0; i < len(x); i++ {
+		for j := i + 1; j < len(x); j++ {
+			dx := sign(x[i] - x[j])
+			dy := sign(y[i] - y[j])
+			switch {
+			case dx == 0 && dy == 0:
+			case dx == 0:
+				tiesX++
+			case dy == 0:
+				tiesY++
+			case dx == dy:
+				concordant++
+			default:
+				discordant++
+			}
+		}
+	}
+	denom := math.Sqrt((concordant + discordant + tiesX) * (concordant + discordant + tiesY))
+	if denom == 0 {
+		return 0
+	}
+	return (concordant - discordant) / denom
+}
+
+func averageRanks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] > values[order[j]] })
+
+	ranks := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+		// Positions start..end-1 share the mean of ranks start+1..end.
+		rank := float64(start+end+1) / 2
+		for k := start; k < end; k++ {
+			ranks[order[k]] = rank
+		}
+		start = end
+	}
+	return ranks
+}
+
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if n < 2 {
+		return 0
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= n
+	meanY /= n
+	var cov, varX, varY float64
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+		varX += (x[i] - meanX) * (x[i] - meanX)
+		varY += (y[i] - meanY) * (y[i] - meanY)
+	}
+	if varX == 0 || varY == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+func sign(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,175 @@
+package recommendation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/geo"
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// Strategies select how teams are split into groups.
+const (
+	// StrategySimple fills the groups greedily in ranking order, so the first
+	// group gets the strongest teams.
+	StrategySimple = "simple"
+	// StrategyBalanced optimizes the groups to have the same average strength.
+	StrategyBalanced = "balanced"
+	// StrategyTiered splits the teams into strength tiers of balanced groups,
+	// like the federation's Leistungsklassen.
+	StrategyTiered = "tiered"
+	// StrategyMinimal moves as few teams of the current groups as needed.
+	StrategyMinimal = "minimal"
+	// StrategyDraw draws the teams from rating pots like the Kreis assembly.
+	StrategyDraw = "draw"
+)
+
+// Strategies lists the available strategies.
+func Strategies() []string {
+	return []string{StrategySimple, StrategyBalanced, StrategyTiered, StrategyMinimal, StrategyDraw}
+}
+
+// ParseStrategy validates a strategy name; empty selects balanced.
+func ParseStrategy(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return StrategyBalanced, nil
+	}
+	for _, strategy := range Strategies() {
+		if value == strategy {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q (want %s)", value, strings.Join(Strategies(), ", "))
+}
+
+// Objective breaks down how balanced a grouping is. Strength is the overall
+// power score.
+type Objective struct {
+	// Variance is the variance of the groups' average strength, the value the
+	// balanced strategy minimizes.
+	Variance float64 `json:"variance"`
+	StdDev   float64 `json:"stdDev"`
+	// Spread is the gap between the strongest and the weakest group average.
+	Spread     float64   `json:"spread"`
+	GroupMeans []float64 `json:"groupMeans"`
+	// ClubConflicts counts pairs of teams of the same club in one group.
+	ClubConflicts int `json:"clubConflicts"`
+	// SizeDeviation is how many teams the groups are off their target sizes.
+	SizeDeviation int `json:"sizeDeviation"`
+	// Travel is set when team locations are known.
+	Travel *Travel `json:"travel,omitempty"`
+}
+
+// BalancedOptions configures BalancedGroups.
+type BalancedOptions struct {
+	Seed       int64
+	Iterations int
+	// StartTemperature scales the initial annealing temperature relative to
+	// the starting variance; the temperature decays to StartTemperature *
+	// EndFactor over the iterations.
+	StartTemperature float64
+	EndFactor        float64
+	// Locations are the teams' home locations keyed by team ID. With
+	// locations, the solver also minimizes the mean travel within groups,
+	// trading TravelWeight of strength variance for each kilometre.
+	Locations    map[string]geo.Location
+	TravelWeight float64
+}
+
+// DefaultBalancedOptions returns the options used by the API.
+func DefaultBalancedOptions() BalancedOptions {
+	return BalancedOptions{Seed: 1, Iterations: 20000, StartTemperature: 0.5, EndFactor: 1e-4, TravelWeight: 1e-4}
+}
+
+// BalancedGroups splits teams into numGroups groups with the same target sizes
+// as SimpleBalancedGroups and minimizes the variance of the groups' average
+// strength by simulated annealing over team swaps. It starts from the simple
+// grouping and never ends with more club conflicts than that. The result is
+// deterministic for a given seed.
+func BalancedGroups(teams []model.TeamPower, numGroups int, opts BalancedOptions) ([]Group, Objective) {
+	groups, objective, _ := ConstrainedGroups(teams, numGroups, Constraints{}, opts)
+	return groups, objective
+}
+
+// BalanceObjective measures a grouping against the target group sizes.
+func BalanceObjective(groups []Group, targets []int) Objective {
+	obj := Objective{GroupMeans: make([]float64, len(groups))}
+	for g, group := range groups {
+		sum := 0.0
+		counts := make(map[string]int)
+		for _, team := range group.Teams {
+			sum += strength(team)
+			if key := clubKey(team.Team); key != "" {
+				obj.ClubConflicts += counts[key]
+				counts[key]++
+			}
+		}
+		if len(group.Teams) > 0 {
+			obj.GroupMeans[g] = sum / float64(len(group.Teams))
+		}
+		if g < len(targets) {
+			obj.SizeDeviation += absInt(len(group.Teams) - targets[g])
+		}
+	}
+	_, obj.Variance = meanVariance(obj.GroupMeans)
+	obj.StdDev = math.Sqrt(obj.Variance)
+	if len(obj.GroupMeans) > 0 {
+		lo, hi := obj.GroupMeans[0], obj.GroupMeans[0]
+		for _, mean := range obj.GroupMeans[1:] {
+			lo, hi = math.Min(lo, mean), math.Max(hi, mean)
+		}
+		obj.Spread = hi - lo
+	}
+	return obj
+}
+
+// TargetSizes spreads total teams over numGroups, the first groups taking the
+// remainder.
+func TargetSizes(total, numGroups int) []int {
+	if numGroups <= 0 {
+		numGroups = 1
+	}
+	sizes := make([]int, numGroups)
+	for i := range sizes {
+		sizes[i] = total / numGroups
+		if i < total%numGroups {
+			sizes[i]++
+		}
+	}
+	return sizes
+}
+
+func moveClub(clubs []map[string]int, club string, from, to int) {
+	if club == "" {
+		return
+	}
+	clubs[from][club]--
+	clubs[to][club]++
+}
+
+func strength(team model.TeamPower) float64 {
+	return team.OverallMetrics.PowerScore
+}
+
+func meanVariance(values []float64) (mean, variance float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(values))
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,675 @@
+package recommendation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/schlubbi/score_board/internal/geo"
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// Constraint kinds and statuses, see ConstraintResult.
+const (
+	KindPin            = "pin"
+	KindApart          = "apart"
+	KindTogether       = "together"
+	KindClubTogether   = "clubTogether"
+	KindMaxSize        = "maxSize"
+	KindClubSeparation = "clubSeparation"
+
+	StatusSatisfied  = "satisfied"
+	StatusRelaxed    = "relaxed"
+	StatusInfeasible = "infeasible"
+)
+
+// Pin places a team in a group. Groups are numbered from 1 like Group.Index.
+type Pin struct {
+	TeamID string `json:"teamId"`
+	Group  int    `json:"group"`
+}
+
+// Pair names two teams by ID.
+type Pair struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// GroupSize caps the number of teams in a group.
+type GroupSize struct {
+	Group int `json:"group"`
+	Max   int `json:"max"`
+}
+
+// Constraints are the hard requirements of a grouping. Pins always hold; the
+// other constraints may be relaxed when they cannot all be met, and club
+// separation gives way to all of them.
+type Constraints struct {
+	Pins     []Pin  `json:"pins,omitempty"`
+	Apart    []Pair `json:"apart,omitempty"`
+	Together []Pair `json:"together,omitempty"`
+	// ClubsTogether lists club IDs whose teams all play in one group. Club
+	// separation does not apply to these clubs.
+	ClubsTogether []string    `json:"clubsTogether,omitempty"`
+	MaxSizes      []GroupSize `json:"maxSizes,omitempty"`
+}
+
+// LoadConstraints reads constraints from a JSON file.
+func LoadConstraints(path string) (Constraints, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Constraints{}, err
+	}
+	var c Constraints
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Constraints{}, fmt.Errorf("parse constraints %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ConstraintResult reports whether a constraint holds in the solution.
+// Infeasible constraints contradict the data or other constraints and were not
+// attempted; relaxed ones were attempted but given up for the others.
+type ConstraintResult struct {
+	Kind       string `json:"kind"`
+	Constraint string `json:"constraint"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Penalties rank the goals of the solver: constraints before club separation
+// before balance. Both outweigh any strength variance; anneal raises them
+// above the largest possible travel term, too.
+const (
+	constraintPenalty = 1e6
+	clubPenalty       = 1e3
+)
+
+type slot struct {
+	team   model.TeamPower
+	group  int
+	club   string
+	pinned bool
+	// loc is the team's home location for the travel term, if known.
+	loc *geo.Location
+}
+
+// link requires slot other to share (together) or not share a group.
+type link struct {
+	other    int
+	together bool
+}
+
+// ConstrainedGroups splits teams into numGroups groups like BalancedGroups
+// while meeting the given constraints, and reports the status of every
+// constraint and of club separation.
+func ConstrainedGroups(teams []model.TeamPower, numGroups int, c Constraints, opts BalancedOptions) ([]Group, Objective, []ConstraintResult) {
+	if numGroups <= 0 {
+		numGroups = 1
+	}
+	report := []ConstraintResult{}
+	names := make(map[string]string, len(teams))
+	slotsOf := make(map[string][]int, len(teams))
+	clubTeams := make(map[string][]int)
+	slots := make([]slot, len(teams))
+	for i, team := range teams {
+		names[team.Team.TeamID] = team.Team.TeamName
+		slotsOf[team.Team.TeamID] = append(slotsOf[team.Team.TeamID], i)
+		slots[i] = slot{team: team, group: -1, club: clubKey(team.Team)}
+		if loc, ok := opts.Locations[team.Team.TeamID]; ok {
+			slots[i].loc = &loc
+		}
+		if slots[i].club != "" {
+			clubTeams[slots[i].club] = append(clubTeams[slots[i].club], i)
+		}
+	}
+	name := func(teamID string) string {
+		if n, ok := names[teamID]; ok {
+			return n
+		}
+		return teamID
+	}
+	infeasible := func(kind, constraint, detail string) {
+		report = append(report, ConstraintResult{Kind: kind, Constraint: constraint, Status: StatusInfeasible, Detail: detail})
+	}
+
+	// Pins are placed up front and never move.
+	pinnedTo := make(map[string]int)
+	pinnedCount := make([]int, numGroups)
+	var pins []Pin
+	for _, pin := range c.Pins {
+		desc := fmt.Sprintf("%s in group %d", name(pin.TeamID), pin.Group)
+		switch group, pinned := pinnedTo[pin.TeamID]; {
+		case len(slotsOf[pin.TeamID]) == 0:
+			infeasible(KindPin, desc, "unknown team")
+		case pin.Group < 1 || pin.Group > numGroups:
+			infeasible(KindPin, desc, fmt.Sprintf("there are %d groups", numGroups))
+		case pinned && group != pin.Group-1:
+			infeasible(KindPin, desc, fmt.Sprintf("already pinned to group %d", group+1))
+		case pinned:
+		default:
+			pinnedTo[pin.TeamID] = pin.Group - 1
+			pins = append(pins, pin)
+			for _, i := range slotsOf[pin.TeamID] {
+				slots[i].group, slots[i].pinned = pin.Group-1, true
+				pinnedCount[pin.Group-1]++
+			}
+		}
+	}
+	for _, pin := range pins {
+		report = append(report, ConstraintResult{Kind: KindPin, Constraint: fmt.Sprintf("%s in group %d", name(pin.TeamID), pin.Group), Status: StatusSatisfied})
+	}
+
+	maxSize := make([]int, numGroups)
+	for g := range maxSize {
+		maxSize[g] = math.MaxInt
+	}
+	var maxSizes []GroupSize
+	for _, size := range c.MaxSizes {
+		desc := fmt.Sprintf("group %d at most %d teams", size.Group, size.Max)
+		switch {
+		case size.Group < 1 || size.Group > numGroups:
+			infeasible(KindMaxSize, desc, fmt.Sprintf("there are %d groups", numGroups))
+		case size.Max < 1:
+			infeasible(KindMaxSize, desc, "maximum must be positive")
+		case size.Max < pinnedCount[size.Group-1]:
+			infeasible(KindMaxSize, desc, fmt.Sprintf("%d teams are pinned to it", pinnedCount[size.Group-1]))
+		default:
+			maxSize[size.Group-1] = min(maxSize[size.Group-1], size.Max)
+			maxSizes = append(maxSizes, size)
+		}
+	}
+
+	// Pairs become links between slots; clubs kept together become links
+	// between all of their teams and are exempt from club separation.
+	links := make([][]link, len(slots))
+	addLink := func(a, b string, together bool) {
+		for _, i := range slotsOf[a] {
+			for _, j := range slotsOf[b] {
+				links[i] = append(links[i], link{other: j, together: together})
+				links[j] = append(links[j], link{other: i, together: together})
+			}
+		}
+	}
+	checkPair := func(kind string, pair Pair) (string, bool) {
+		verb := "apart from"
+		if kind == KindTogether {
+			verb = "together with"
+		}
+		desc := fmt.Sprintf("%s %s %s", name(pair.A), verb, name(pair.B))
+		ga, pinnedA := pinnedTo[pair.A]
+		gb, pinnedB := pinnedTo[pair.B]
+		switch {
+		case len(slotsOf[pair.A]) == 0 || len(slotsOf[pair.B]) == 0:
+			infeasible(kind, desc, "unknown team")
+		case pair.A == pair.B:
+			infeasible(kind, desc, "a team cannot be paired with itself")
+		case pinnedA && pinnedB && (ga == gb) != (kind == KindTogether):
+			infeasible(kind, desc, "contradicts the pins")
+		default:
+			return desc, true
+		}
+		return desc, false
+	}
+	type pairCheck struct {
+		kind string
+		desc string
+		pair Pair
+	}
+	var pairChecks []pairCheck
+	for _, pair := range c.Apart {
+		if desc, ok := checkPair(KindApart, pair); ok {
+			addLink(pair.A, pair.B, false)
+			pairChecks = append(pairChecks, pairCheck{KindApart, desc, pair})
+		}
+	}
+	for _, pair := range c.Together {
+		if desc, ok := checkPair(KindTogether, pair); ok {
+			addLink(pair.A, pair.B, true)
+			pairChecks = append(pairChecks, pairCheck{KindTogether, desc, pair})
+		}
+	}
+	var clubsTogether []string
+	for _, clubID := range c.ClubsTogether {
+		desc := fmt.Sprintf("club %s together", clubID)
+		members := clubTeams[clubID]
+		groups := make(map[int]bool)
+		for _, i := range members {
+			if slots[i].pinned {
+				groups[slots[i].group] = true
+			}
+		}
+		switch {
+		case len(members) == 0:
+			infeasible(KindClubTogether, desc, "unknown club")
+		case len(groups) > 1:
+			infeasible(KindClubTogether, desc, "its teams are pinned to different groups")
+		default:
+			clubsTogether = append(clubsTogether, clubID)
+			for x, i := range members {
+				for _, j := range members[x+1:] {
+					links[i] = append(links[i], link{other: j, together: true})
+					links[j] = append(links[j], link{other: i, together: true})
+				}
+				slots[i].club = ""
+			}
+		}
+	}
+
+	sizes, fits := fitSizes(TargetSizes(len(slots), numGroups), pinnedCount, maxSize)
+	if !fits {
+		for _, size := range maxSizes {
+			report = append(report, ConstraintResult{Kind: KindMaxSize, Constraint: fmt.Sprintf("group %d at most %d teams", size.Group, size.Max), Status: StatusInfeasible, Detail: "the groups cannot hold all teams"})
+		}
+		maxSizes = nil
+	}
+
+	// Greedy start like SimpleBalancedGroups: fill the groups in ranking order,
+	// avoiding a second team of the same club where possible.
+	count := make([]int, numGroups)
+	clubs := make([]map[string]int, numGroups)
+	for g := range clubs {
+		clubs[g] = make(map[string]int)
+	}
+	place := func(i, g int) {
+		slots[i].group = g
+		count[g]++
+		if slots[i].club != "" {
+			clubs[g][slots[i].club]++
+		}
+	}
+	for i := range slots {
+		if slots[i].pinned {
+			g := slots[i].group
+			slots[i].group = -1
+			place(i, g)
+		}
+	}
+	for i := range slots {
+		if slots[i].pinned {
+			continue
+		}
+		target := -1
+		for g := range count {
+			if count[g] >= sizes[g] {
+				continue
+			}
+			if target < 0 {
+				target = g
+			}
+			if slots[i].club == "" || clubs[g][slots[i].club] == 0 {
+				target = g
+				break
+			}
+		}
+		place(i, target)
+	}
+
+	if numGroups >= 2 && opts.Iterations > 0 {
+		anneal(slots, links, clubs, count, opts)
+	}
+
+	groups := make([]Group, numGroups)
+	for g := range groups {
+		groups[g] = Group{Index: g + 1, Teams: []model.TeamPower{}}
+	}
+	// Slots follow the input ranking order, strongest first.
+	for _, s := range slots {
+		groups[s.group].Teams = append(groups[s.group].Teams, s.team)
+	}
+
+	sameGroup := func(a, b string) bool {
+		return slots[slotsOf[a][0]].group == slots[slotsOf[b][0]].group
+	}
+	status := func(ok bool) string {
+		if ok {
+			return StatusSatisfied
+		}
+		return StatusRelaxed
+	}
+	for _, check := range pairChecks {
+		ok := sameGroup(check.pair.A, check.pair.B) == (check.kind == KindTogether)
+		report = append(report, ConstraintResult{Kind: check.kind, Constraint: check.desc, Status: status(ok)})
+	}
+	for _, clubID := range clubsTogether {
+		members := clubTeams[clubID]
+		ok := true
+		for _, i := range members[1:] {
+			ok = ok && slots[i].group == slots[members[0]].group
+		}
+		report = append(report, ConstraintResult{Kind: KindClubTogether, Constraint: fmt.Sprintf("club %s together", clubID), Status: status(ok)})
+	}
+	for _, size := range maxSizes {
+		report = append(report, ConstraintResult{Kind: KindMaxSize, Constraint: fmt.Sprintf("group %d at most %d teams", size.Group, size.Max), Status: status(count[size.Group-1] <= size.Max)})
+	}
+	conflicts := 0
+	for g := range clubs {
+		for _, n := range clubs[g] {
+			conflicts += n * (n - 1) / 2
+		}
+	}
+	separation := ConstraintResult{Kind: KindClubSeparation, Constraint: "teams of one club in different groups", Status: status(conflicts == 0)}
+	if conflicts > 0 {
+		separation.Detail = fmt.Sprintf("%d pairs of teams of one club share a group", conflicts)
+	}
+	report = append(report, separation)
+
+	return groups, Measure(groups, sizes, opts.Locations), report
+}
+
+// fitSizes adjusts the target group sizes so that every group holds its
+// pinned teams and stays within its maximum. It reports false when the
+// maximums leave no room for all teams; the surplus then goes to the smallest
+// groups.
+func fitSizes(targets, pinned, maxSize []int) ([]int, bool) {
+	sizes := make([]int, len(targets))
+	diff := 0
+	for g, target := range targets {
+		sizes[g] = max(pinned[g], min(target, maxSize[g]))
+		diff += target - sizes[g]
+	}
+	fits := true
+	for ; diff > 0; diff-- {
+		best := -1
+		for g := range sizes {
+			if sizes[g] < maxSize[g] && (best < 0 || sizes[g] < sizes[best]) {
+				best = g
+			}
+		}
+		if best < 0 {
+			fits = false
+			for g := range sizes {
+				if best < 0 || sizes[g] < sizes[best] {
+					best = g
+				}
+			}
+		}
+		sizes[best]++
+	}
+	for ; diff < 0; diff++ {
+		best := -1
+		for g := len(sizes) - 1; g >= 0; g-- {
+			if sizes[g] > pinned[g] && (best < 0 || sizes[g] > sizes[best]) {
+				best = g
+			}
+		}
+		sizes[best]--
+	}
+	return sizes, fits
+}
+
+// anneal improves the assignment in slots by simulated annealing over swaps of
+// unpinned teams, which keep the group sizes. It minimizes the variance of the
+// groups' average strength plus the weighted travel, with penalties for broken
+// links and club conflicts, and leaves slots, clubs and count at the best
+// assignment found.
+func anneal(slots []slot, links [][]link, clubs []map[string]int, count []int, opts BalancedOptions) {
+	sums := make([]float64, len(count))
+	var movable []int
+	for i, s := range slots {
+		sums[s.group] += strength(s.team)
+		if !s.pinned {
+			movable = append(movable, i)
+		}
+	}
+	if len(movable) < 2 {
+		return
+	}
+
+	// distances sums the distances between located teams per group.
+	distances := make([]float64, len(count))
+	located := make([]int, len(count))
+	// distTo sums the distances from slot i to the located teams of group g,
+	// leaving out slots a and b.
+	distTo := func(i, g, a, b int) float64 {
+		if slots[i].loc == nil {
+			return 0
+		}
+		d := 0.0
+		for j, s := range slots {
+			if s.group == g && j != a && j != b && s.loc != nil {
+				d += geo.DistanceKm(*slots[i].loc, *s.loc)
+			}
+		}
+		return d
+	}
+	if opts.TravelWeight > 0 {
+		for i, s := range slots {
+			if s.loc != nil {
+				distances[s.group] += distTo(i, s.group, i, -1) / 2
+				located[s.group]++
+			}
+		}
+	}
+	travel := func() float64 {
+		return meanPairDistance(distances, located)
+	}
+	// The travel term is at most TravelWeight times the longest distance
+	// between two teams, so however large the weight, the penalties scale
+	// with it and club separation still comes first.
+	penaltyScale := 1.0
+	if opts.TravelWeight > 0 {
+		longest := 0.0
+		for i, a := range slots {
+			for _, b := range slots[i+1:] {
+				if a.loc != nil && b.loc != nil {
+					longest = max(longest, geo.DistanceKm(*a.loc, *b.loc))
+				}
+			}
+		}
+		penaltyScale += opts.TravelWeight * longest
+	}
+	constraintWeight, clubWeight := constraintPenalty*penaltyScale, clubPenalty*penaltyScale
+
+	variance := func() float64 {
+		means := make([]float64, len(sums))
+		for g := range sums {
+			if count[g] > 0 {
+				means[g] = sums[g] / float64(count[g])
+			}
+		}
+		_, v := meanVariance(means)
+		return v
+	}
+	conflicts := 0
+	for g := range clubs {
+		for _, n := range clubs[g] {
+			conflicts += n * (n - 1) / 2
+		}
+	}
+	// broken counts the violated links of slot i, skipping the one to skip.
+	broken := func(i, skip int) int {
+		n := 0
+		for _, l := range links[i] {
+			if l.other != skip && (slots[l.other].group == slots[i].group) != l.together {
+				n++
+			}
+		}
+		return n
+	}
+	violations := 0
+	for i := range slots {
+		violations += broken(i, -1)
+	}
+	violations /= 2
+	// conflictDelta is the change in conflicts when a team of club leaves
+	// group from and joins group to, ignoring the team coming back.
+	conflictDelta := func(club string, from, to int) int {
+		if club == "" {
+			return 0
+		}
+		return clubs[to][club] - (clubs[from][club] - 1)
+	}
+
+	balance := func() float64 {
+		return variance() + opts.TravelWeight*travel()
+	}
+	cost := balance() + constraintWeight*float64(violations) + clubWeight*float64(conflicts)
+	temperature := opts.StartTemperature * balance()
+	cooling := 1.0
+	if opts.EndFactor > 0 && opts.EndFactor < 1 {
+		cooling = math.Pow(opts.EndFactor, 1/float64(opts.Iterations))
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	best := make([]int, len(slots))
+	bestCost := cost
+	for i := range slots {
+		best[i] = slots[i].group
+	}
+
+	for it := 0; it < opts.Iterations; it++ {
+		a, b := movable[rng.Intn(len(movable))], movable[rng.Intn(len(movable))]
+		ga, gb := slots[a].group, slots[b].group
+		if ga == gb {
+			temperature *= cooling
+			continue
+		}
+
+		conflict := 0
+		if slots[a].club != slots[b].club {
+			conflict = conflictDelta(slots[a].club, ga, gb) + conflictDelta(slots[b].club, gb, ga)
+		}
+		var travelA, travelB float64
+		var movedLoc int
+		if opts.TravelWeight > 0 {
+			travelA = distTo(b, ga, a, b) - distTo(a, ga, a, b)
+			travelB = distTo(a, gb, a, b) - distTo(b, gb, a, b)
+			movedLoc = boolInt(slots[a].loc != nil) - boolInt(slots[b].loc != nil)
+		}
+		before := broken(a, b) + broken(b, a)
+		slots[a].group, slots[b].group = gb, ga
+		violation := broken(a, b) + broken(b, a) - before
+		diff := strength(slots[a].team) - strength(slots[b].team)
+		sums[ga] -= diff
+		sums[gb] += diff
+		distances[ga] += travelA
+		distances[gb] += travelB
+		located[ga] -= movedLoc
+		located[gb] += movedLoc
+		newCost := balance() + constraintWeight*float64(violations+violation) + clubWeight*float64(conflicts+conflict)
+
+		if newCost <= cost || (temperature > 0 && rng.Float64() < math.Exp((cost-newCost)/temperature)) {
+			moveClub(clubs, slots[a].club, ga, gb)
+			moveClub(clubs, slots[b].club, gb, ga)
+			violations += violation
+			conflicts += conflict
+			cost = newCost
+			if cost < bestCost {
+				bestCost = cost
+				for i := range slots {
+					best[i] = slots[i].group
+				}
+			}
+		} else {
+			slots[a].group, slots[b].group = ga, gb
+			sums[ga] += diff
+			sums[gb] -= diff
+			distances[ga] -= travelA
+			distances[gb] -= travelB
+			located[ga] += movedLoc
+			located[gb] -= movedLoc
+		}
+		temperature *= cooling
+	}
+
+	for g := range clubs {
+		clubs[g] = make(map[string]int)
+	}
+	for i := range slots {
+		slots[i].group = best[i]
+		if slots[i].club != "" {
+			clubs[best[i]][slots[i].club]++
+		}
+	}
+}
+
+// Travel summarizes how far teams of the same group live apart, as the
+// straight-line distance between their home locations. Every team visits every
+// other team of its group once, so the average over pairs is the typical away
+// trip.
+type Travel struct {
+	// MeanKm is the mean of the groups' average trips.
+	MeanKm float64       `json:"meanKm"`
+	MaxKm  float64       `json:"maxKm"`
+	Groups []GroupTravel `json:"groups"`
+	// Unlocated lists the teams without a location; they add no travel.
+	Unlocated []string `json:"unlocated"`
+}
+
+// GroupTravel is the travel within one group.
+type GroupTravel struct {
+	Group   int     `json:"group"`
+	AvgKm   float64 `json:"avgKm"`
+	MaxKm   float64 `json:"maxKm"`
+	Located int     `json:"located"`
+}
+
+// Measure is BalanceObjective with the travel for the given team locations,
+// if any.
+func Measure(groups []Group, targets []int, locations map[string]geo.Location) Objective {
+	objective := BalanceObjective(groups, targets)
+	if len(locations) > 0 {
+		objective.Travel = MeasureTravel(groups, locations)
+	}
+	return objective
+}
+
+// MeasureTravel reports the travel within each group for the given team
+// locations, keyed by team ID.
+func MeasureTravel(groups []Group, locations map[string]geo.Location) *Travel {
+	t := &Travel{Groups: make([]GroupTravel, 0, len(groups)), Unlocated: []string{}}
+	distances := make([]float64, len(groups))
+	located := make([]int, len(groups))
+	for g, group := range groups {
+		gt := GroupTravel{Group: group.Index}
+		var locs []geo.Location
+		for _, team := range group.Teams {
+			loc, ok := locations[team.Team.TeamID]
+			if !ok {
+				t.Unlocated = append(t.Unlocated, team.Team.TeamName)
+				continue
+			}
+			for _, other := range locs {
+				d := geo.DistanceKm(loc, other)
+				distances[g] += d
+				gt.MaxKm = math.Max(gt.MaxKm, d)
+			}
+			locs = append(locs, loc)
+		}
+		located[g] = len(locs)
+		gt.Located = len(locs)
+		if pairs := len(locs) * (len(locs) - 1) / 2; pairs > 0 {
+			gt.AvgKm = distances[g] / float64(pairs)
+		}
+		t.MaxKm = math.Max(t.MaxKm, gt.MaxKm)
+		t.Groups = append(t.Groups, gt)
+	}
+	t.MeanKm = meanPairDistance(distances, located)
+	return t
+}
+
+// meanPairDistance averages the groups' mean distance between located teams,
+// over the groups with at least two of them.
+func meanPairDistance(distances []float64, located []int) float64 {
+	sum, groups := 0.0, 0
+	for g := range distances {
+		if pairs := located[g] * (located[g] - 1) / 2; pairs > 0 {
+			sum += distances[g] / float64(pairs)
+			groups++
+		}
+	}
+	if groups == 0 {
+		return 0
+	}
+	return sum / float64(groups)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,335 @@
+package recommendation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// DrawOptions configures Draw and SimulateDraws.
+type DrawOptions struct {
+	Seed int64
+	// Simulations is the number of draws SimulateDraws runs.
+	Simulations int
+}
+
+// DefaultDrawOptions returns the options used by the API.
+func DefaultDrawOptions() DrawOptions {
+	return DrawOptions{Seed: 1, Simulations: 1000}
+}
+
+// Validate reports options that cannot be drawn.
+func (o DrawOptions) Validate() error {
+	if o.Simulations < 0 || o.Simulations > 100000 {
+		return errors.New("simulations must be between 0 and 100000")
+	}
+	return nil
+}
+
+// Pot is one rating band of the draw: pot 1 holds the numGroups strongest
+// teams, pot 2 the next, and so on.
+type Pot struct {
+	Pot       int               `json:"pot"`
+	Teams     []model.TeamStats `json:"teams"`
+	MaxRating float64           `json:"maxRating"`
+	MinRating float64           `json:"minRating"`
+}
+
+// DrawStep is one ball taken out of a pot.
+type DrawStep struct {
+	Step   int             `json:"step"`
+	Pot    int             `json:"pot"`
+	Team   model.TeamStats `json:"team"`
+	Rating float64         `json:"rating"`
+	Group  int             `json:"group"`
+	// Skipped lists the groups without a team of the pot that were passed
+	// over, with the rule that excluded them.
+	Skipped []SkippedGroup `json:"skipped,omitempty"`
+	// Conflict is set when the team had to join a group with a club mate.
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// SkippedGroup is a group a drawn team could not go to.
+type SkippedGroup struct {
+	Group  int    `json:"group"`
+	Reason string `json:"reason"`
+}
+
+// DrawResult is the outcome of one seeded draw.
+type DrawResult struct {
+	Seed      int64      `json:"seed"`
+	Pots      []Pot      `json:"pots"`
+	Log       []DrawStep `json:"log"`
+	Groups    []Group    `json:"groups"`
+	Objective Objective  `json:"objective"`
+}
+
+// Draw runs a pot draw the way the Kreis does it at the assembly. The ranked
+// teams are cut into pots of numGroups teams; the pots are drawn in order and
+// every drawn team goes to the first group, counting from group 1, that has no
+// team of the pot yet and no team of its club (by ClubID, or the base name when
+// it is unset). A group is also passed over when taking it would leave a later
+// team of the pot without a valid group that it would otherwise have. Only when
+// no group is valid does a team join a club mate. The draw is deterministic for
+// a given seed.
+func Draw(sortedTeams []model.TeamPower, numGroups int, seed int64) DrawResult {
+	if numGroups <= 0 {
+		numGroups = 1
+	}
+	rng := rand.New(rand.NewSource(seed))
+	result := DrawResult{Seed: seed, Pots: []Pot{}, Log: []DrawStep{}, Groups: make([]Group, numGroups)}
+	for g := range result.Groups {
+		result.Groups[g] = Group{Index: g + 1, Teams: []model.TeamPower{}}
+	}
+	clubs := make([]map[string]bool, numGroups)
+	for g := range clubs {
+		clubs[g] = make(map[string]bool)
+	}
+
+	for start := 0; start < len(sortedTeams); start += numGroups {
+		end := start + numGroups
+		if end > len(sortedTeams) {
+			end = len(sortedTeams)
+		}
+		potTeams := sortedTeams[start:end]
+		pot := Pot{Pot: len(result.Pots) + 1, Teams: make([]model.TeamStats, len(potTeams))}
+		for i, team := range potTeams {
+			pot.Teams[i] = team.Team
+		}
+		pot.MaxRating, pot.MinRating = strength(potTeams[0]), strength(potTeams[len(potTeams)-1])
+		result.Pots = append(result.Pots, pot)
+
+		remaining := append([]model.TeamPower(nil), potTeams...)
+		open := make([]bool, numGroups)
+		for g := range open {
+			open[g] = true
+		}
+		for len(remaining) > 0 {
+			pick := rng.Intn(len(remaining))
+			team := remaining[pick]
+			remaining = append(remaining[:pick], remaining[pick+1:]...)
+			key := clubKey(team.Team)
+
+			// Groups are kept free for the teams still in the pot only while
+			// they can all avoid a club mate. Once a conflict is unavoidable,
+			// the team takes the first group without a club mate and a later
+			// team takes the conflict.
+			step := DrawStep{Step: len(result.Log) + 1, Pot: pot.Pot, Team: team.Team, Rating: strength(team), Group: -1}
+			keepFree := potMatchable(remaining, open, clubs)
+			for {
+				step.Skipped = nil
+				for g := 0; g < numGroups; g++ {
+					switch {
+					case !open[g]:
+						continue
+					case key != "" && clubs[g][key]:
+						step.Skipped = append(step.Skipped, SkippedGroup{Group: g + 1, Reason: "already has a team of this club"})
+						continue
+					}
+					if keepFree {
+						open[g] = false
+						ok := potMatchable(remaining, open, clubs)
+						open[g] = true
+						if !ok {
+							step.Skipped = append(step.Skipped, SkippedGroup{Group: g + 1, Reason: "needed by a team still in the pot"})
+							continue
+						}
+					}
+					step.Group = g
+					break
+				}
+				if step.Group >= 0 || !keepFree {
+					break
+				}
+				keepFree = false
+			}
+			if step.Group < 0 {
+				// No group without a club mate is left: take the first open one.
+				step.Conflict = true
+				for g := range open {
+					if open[g] {
+						step.Group = g
+						break
+					}
+				}
+			}
+
+			open[step.Group] = false
+			if key != "" {
+				clubs[step.Group][key] = true
+			}
+			result.Groups[step.Group].Teams = append(result.Groups[step.Group].Teams, team)
+			step.Group++
+			result.Log = append(result.Log, step)
+		}
+	}
+
+	sizes := make([]int, numGroups)
+	for g, group := range result.Groups {
+		sizes[g] = len(group.Teams)
+	}
+	result.Objective = BalanceObjective(result.Groups, sizes)
+	return result
+}
+
+// potMatchable reports whether every team can still get its own open group
+// without a club mate, by finding a bipartite matching with augmenting paths.
+func potMatchable(teams []model.TeamPower, open []bool, clubs []map[string]bool) bool {
+	owner := make([]int, len(open))
+	for g := range owner {
+		owner[g] = -1
+	}
+	var augment func(t int, seen []bool) bool
+	augment = func(t int, seen []bool) bool {
+		key := clubKey(teams[t].Team)
+		for g := range open {
+			if !open[g] || seen[g] || (key != "" && clubs[g][key]) {
+				continue
+			}
+			seen[g] = true
+			if owner[g] < 0 || augment(owner[g], seen) {
+				owner[g] = t
+				return true
+			}
+		}
+		return false
+	}
+	for t := range teams {
+		if !augment(t, make([]bool, len(open))) {
+			return false
+		}
+	}
+	return true
+}
+
+// Distribution summarizes a sample of values.
+type Distribution struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P5   float64 `json:"p5"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	Max  float64 `json:"max"`
+}
+
+// HistogramBin counts the values in [Lo, Hi).
+type HistogramBin struct {
+	Lo    float64 `json:"lo"`
+	Hi    float64 `json:"hi"`
+	Count int     `json:"count"`
+}
+
+// DrawSimulation summarizes many draws.
+type DrawSimulation struct {
+	Draws int `json:"draws"`
+	// Seeds are Seed, Seed+1, ..., so any draw can be repeated with Draw.
+	Seed int64 `json:"seed"`
+	// Spread is the gap between the strongest and weakest group per draw.
+	Spread Distribution `json:"spread"`
+	StdDev Distribution `json:"stdDev"`
+	// GroupMeans pools the average strength of every group of every draw.
+	GroupMeans Distribution   `json:"groupMeans"`
+	Histogram  []HistogramBin `json:"histogram"`
+	// ConflictDraws counts the draws that put club mates together.
+	ConflictDraws int `json:"conflictDraws"`
+	// MostBalancedSeed and LeastBalancedSeed are the draws with the smallest
+	// and largest spread.
+	MostBalancedSeed  int64 `json:"mostBalancedSeed"`
+	LeastBalancedSeed int64 `json:"leastBalancedSeed"`
+}
+
+const histogramBins = 20
+
+// SimulateDraws runs opts.Simulations draws with consecutive seeds and reports
+// how strong the groups come out.
+func SimulateDraws(sortedTeams []model.TeamPower, numGroups int, opts DrawOptions) DrawSimulation {
+	sim := DrawSimulation{Draws: opts.Simulations, Seed: opts.Seed, Histogram: []HistogramBin{}}
+	if opts.Simulations <= 0 {
+		return sim
+	}
+	spreads := make([]float64, 0, opts.Simulations)
+	stdDevs := make([]float64, 0, opts.Simulations)
+	var means []float64
+	best, worst := math.Inf(1), math.Inf(-1)
+	for i := 0; i < opts.Simulations; i++ {
+		seed := opts.Seed + int64(i)
+		obj := Draw(sortedTeams, numGroups, seed).Objective
+		if obj.Spread < best {
+			best, sim.MostBalancedSeed = obj.Spread, seed
+		}
+		if obj.Spread > worst {
+			worst, sim.LeastBalancedSeed = obj.Spread, seed
+		}
+		spreads = append(spreads, obj.Spread)
+		stdDevs = append(stdDevs, obj.StdDev)
+		means = append(means, obj.GroupMeans...)
+		if obj.ClubConflicts > 0 {
+			sim.ConflictDraws++
+		}
+	}
+	sim.Spread = distribution(spreads)
+	sim.StdDev = distribution(stdDevs)
+	sim.GroupMeans = distribution(means)
+	sim.Histogram = histogram(means, sim.GroupMeans.Min, sim.GroupMeans.Max)
+	return sim
+}
+
+func distribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mean, _ := meanVariance(sorted)
+	return Distribution{
+		Mean: mean,
+		Min:  sorted[0],
+		P5:   percentile(sorted, 0.05),
+		P50:  percentile(sorted, 0.5),
+		P95:  percentile(sorted, 0.95),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// percentile interpolates linearly between the closest ranks of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+func histogram(values []float64, lo, hi float64) []HistogramBin {
+	bins := make([]HistogramBin, histogramBins)
+	width := (hi - lo) / histogramBins
+	for b := range bins {
+		bins[b].Lo = lo + float64(b)*width
+		bins[b].Hi = lo + float64(b+1)*width
+	}
+	for _, v := range values {
+		b := 0
+		if width > 0 {
+			b = int((v - lo) / width)
+		}
+		if b >= histogramBins {
+			b = histogramBins - 1
+		}
+		bins[b].Count++
+	}
+	return bins
+}
+
+// String describes the step for logs, e.g. "3. pot 1: KSV Baunatal -> group 2".
+func (s DrawStep) String() string {
+	text := fmt.Sprintf("%d. pot %d: %s -> group %d", s.Step, s.Pot, s.Team.TeamName, s.Group)
+	if s.Conflict {
+		text += " (club conflict)"
+	}
+	return text
+}
@@ -0,0 +1,323 @@
+package recommendation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// Goals of MinimalChange.
+const (
+	// GoalBalance evens out the groups' average strength.
+	GoalBalance = "balance"
+	// GoalTiers sorts the groups into strength tiers, strongest group first.
+	GoalTiers = "tiers"
+)
+
+// MinimalOptions configures MinimalChange.
+type MinimalOptions struct {
+	Goal string
+	// Target is the metric value to reach: the spread of the group averages
+	// for GoalBalance, the rating overlap between consecutive groups for
+	// GoalTiers.
+	Target   float64
+	MaxMoves int
+	// SizeSlack is how many teams a group may grow or shrink by.
+	SizeSlack int
+}
+
+// DefaultMinimalOptions returns the options used by the API for goal.
+func DefaultMinimalOptions(goal string) MinimalOptions {
+	opts := MinimalOptions{Goal: GoalBalance, Target: 0.1, MaxMoves: 20, SizeSlack: 1}
+	if goal == GoalTiers {
+		opts.Goal, opts.Target = GoalTiers, 0
+	}
+	return opts
+}
+
+// ParseGoal validates a goal name; empty selects balance.
+func ParseGoal(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", GoalBalance:
+		return GoalBalance, nil
+	case GoalTiers:
+		return GoalTiers, nil
+	}
+	return "", fmt.Errorf("goal must be %s or %s", GoalBalance, GoalTiers)
+}
+
+// Validate reports options that cannot be searched.
+func (o MinimalOptions) Validate() error {
+	if o.Target < 0 || math.IsNaN(o.Target) {
+		return errors.New("target must not be negative")
+	}
+	if o.MaxMoves < 0 || o.SizeSlack < 0 {
+		return errors.New("maxMoves and sizeSlack must not be negative")
+	}
+	return nil
+}
+
+// Move relocates one team. Both teams of a swap share a step; Metric is the
+// goal's metric after the step.
+type Move struct {
+	Step     int             `json:"step"`
+	Team     model.TeamStats `json:"team"`
+	Rating   float64         `json:"rating"`
+	From     string          `json:"from"`
+	FromName string          `json:"fromName"`
+	To       string          `json:"to"`
+	ToName   string          `json:"toName"`
+	Reason   string          `json:"reason"`
+	Metric   float64         `json:"metric"`
+}
+
+// MinimalResult is the output of MinimalChange.
+type MinimalResult struct {
+	Goal   string  `json:"goal"`
+	Target float64 `json:"target"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	// Reached reports whether the target was met within MaxMoves.
+	Reached   bool      `json:"reached"`
+	Moves     []Move    `json:"moves"`
+	Groups    []Group   `json:"groups"`
+	Objective Objective `json:"objective"`
+}
+
+// MinimalChange starts from the current groups (TeamStats.GroupID) and moves
+// as few teams as it can until the goal's metric reaches the target. Each step
+// takes the single move, or the swap of two teams, that improves the metric
+// most per team moved, so the result is greedy rather than proven minimal.
+// Moves never put a second team of a club into a group, and group sizes stay
+// within SizeSlack of the current ones. For GoalTiers, groups keep their
+// current order by average strength. Teams of groups not listed are ignored.
+func MinimalChange(teams []model.TeamPower, groups []model.GroupConfig, opts MinimalOptions) MinimalResult {
+	index := make(map[string]int, len(groups))
+	for g, group := range groups {
+		index[group.ID] = g
+	}
+	var members []model.TeamPower
+	var groupOf []int
+	sizes := make([]int, len(groups))
+	for _, team := range teams {
+		g, ok := index[team.Team.GroupID]
+		if !ok {
+			continue
+		}
+		members = append(members, team)
+		groupOf = append(groupOf, g)
+		sizes[g]++
+	}
+
+	// Tiers follow the current order of the group averages.
+	rank := make([]int, len(groups))
+	{
+		means := groupMeans(members, groupOf, len(groups))
+		order := make([]int, len(groups))
+		for g := range order {
+			order[g] = g
+		}
+		sort.SliceStable(order, func(i, j int) bool { return means[order[i]] > means[order[j]] })
+		for r, g := range order {
+			rank[g] = r
+		}
+	}
+	metric := func(assign []int) float64 {
+		if opts.Goal == GoalTiers {
+			return tierOverlap(members, assign, rank)
+		}
+		return spread(groupMeans(members, assign, len(groups)))
+	}
+
+	count := append([]int(nil), sizes...)
+	clubs := make([]map[string]int, len(groups))
+	for g := range clubs {
+		clubs[g] = make(map[string]int)
+	}
+	for i, team := range members {
+		if key := clubKey(team.Team); key != "" {
+			clubs[groupOf[i]][key]++
+		}
+	}
+	// fits reports whether team i can join group to, with team j (or -1)
+	// leaving it in exchange.
+	fits := func(i, to, j int) bool {
+		if j < 0 && (count[to]+1 > sizes[to]+opts.SizeSlack || count[groupOf[i]]-1 < sizes[groupOf[i]]-opts.SizeSlack) {
+			return false
+		}
+		key := clubKey(members[i].Team)
+		if key == "" {
+			return true
+		}
+		n := clubs[to][key]
+		if j >= 0 && clubKey(members[j].Team) == key {
+			n--
+		}
+		return n == 0
+	}
+	move := func(i, to int) {
+		if key := clubKey(members[i].Team); key != "" {
+			clubs[groupOf[i]][key]--
+			clubs[to][key]++
+		}
+		count[groupOf[i]]--
+		count[to]++
+		groupOf[i] = to
+	}
+
+	result := MinimalResult{Goal: opts.Goal, Target: opts.Target, Before: metric(groupOf), Moves: []Move{}}
+	value, step := result.Before, 0
+	for len(result.Moves) < opts.MaxMoves && value > opts.Target {
+		bestGain, bestI, bestTo, bestJ := 0.0, -1, -1, -1
+		var bestValue float64
+		for i := range members {
+			from := groupOf[i]
+			for to := range groups {
+				if to == from || !fits(i, to, -1) {
+					continue
+				}
+				groupOf[i] = to
+				v := metric(groupOf)
+				groupOf[i] = from
+				if gain := value - v; gain > bestGain+1e-12 {
+					bestGain, bestI, bestTo, bestJ, bestValue = gain, i, to, -1, v
+				}
+			}
+		}
+		if len(result.Moves)+2 <= opts.MaxMoves {
+			for i := range members {
+				for j := i + 1; j < len(members); j++ {
+					gi, gj := groupOf[i], groupOf[j]
+					if gi == gj || !fits(i, gj, j) || !fits(j, gi, i) {
+						continue
+					}
+					groupOf[i], groupOf[j] = gj, gi
+					v := metric(groupOf)
+					groupOf[i], groupOf[j] = gi, gj
+					// A swap moves two teams, so it must gain twice as much.
+					if gain := (value - v) / 2; gain > bestGain+1e-12 {
+						bestGain, bestI, bestTo, bestJ, bestValue = gain, i, gj, j, v
+					}
+				}
+			}
+		}
+		if bestI < 0 {
+			break
+		}
+
+		before := groupMeans(members, groupOf, len(groups))
+		step++
+		if bestJ >= 0 {
+			from := groupOf[bestI]
+			move(bestI, bestTo)
+			move(bestJ, from)
+			result.Moves = append(result.Moves,
+				newMove(step, members[bestI], groups[from], groups[bestTo], swapReason(opts.Goal, members[bestI], members[bestJ], before[from], before[bestTo]), bestValue),
+				newMove(step, members[bestJ], groups[bestTo], groups[from], fmt.Sprintf("swapped with %s", members[bestI].Team.TeamName), bestValue))
+		} else {
+			from := groupOf[bestI]
+			move(bestI, bestTo)
+			result.Moves = append(result.Moves, newMove(step, members[bestI], groups[from], groups[bestTo], moveReason(opts.Goal, members[bestI], before[from], before[bestTo]), bestValue))
+		}
+		value = bestValue
+	}
+	result.After = value
+	result.Reached = value <= opts.Target
+
+	result.Groups = make([]Group, len(groups))
+	for g, group := range groups {
+		result.Groups[g] = Group{Index: g + 1, GroupID: group.ID, GroupName: group.Name, Teams: []model.TeamPower{}}
+	}
+	for i, team := range members {
+		team.Team.GroupID, team.Team.GroupName = groups[groupOf[i]].ID, groups[groupOf[i]].Name
+		result.Groups[groupOf[i]].Teams = append(result.Groups[groupOf[i]].Teams, team)
+	}
+	result.Objective = BalanceObjective(result.Groups, sizes)
+	return result
+}
+
+func newMove(step int, team model.TeamPower, from, to model.GroupConfig, reason string, metric float64) Move {
+	return Move{
+		Step:     step,
+		Team:     team.Team,
+		Rating:   strength(team),
+		From:     from.ID,
+		FromName: from.Name,
+		To:       to.ID,
+		ToName:   to.Name,
+		Reason:   reason,
+		Metric:   metric,
+	}
+}
+
+func moveReason(goal string, team model.TeamPower, fromMean, toMean float64) string {
+	if goal == GoalTiers {
+		return fmt.Sprintf("rated %.2f, closer to the tier of the new group (average %.2f) than of the old one (average %.2f)", strength(team), toMean, fromMean)
+	}
+	direction := "weaker"
+	if toMean > fromMean {
+		direction = "stronger"
+	}
+	return fmt.Sprintf("rated %.2f, leaves a group averaging %.2f for a %s one averaging %.2f", strength(team), fromMean, direction, toMean)
+}
+
+func swapReason(goal string, team, other model.TeamPower, fromMean, toMean float64) string {
+	if goal == GoalTiers {
+		return fmt.Sprintf("rated %.2f, trades places with %s (%.2f) to sort the tiers", strength(team), other.Team.TeamName, strength(other))
+	}
+	return fmt.Sprintf("rated %.2f, trades places with %s (%.2f) to move the group averages %.2f and %.2f closer", strength(team), other.Team.TeamName, strength(other), fromMean, toMean)
+}
+
+func groupMeans(teams []model.TeamPower, groupOf []int, groups int) []float64 {
+	sums := make([]float64, groups)
+	counts := make([]int, groups)
+	for i, team := range teams {
+		sums[groupOf[i]] += strength(team)
+		counts[groupOf[i]]++
+	}
+	for g := range sums {
+		if counts[g] > 0 {
+			sums[g] /= float64(counts[g])
+		}
+	}
+	return sums
+}
+
+func spread(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return hi - lo
+}
+
+// tierOverlap sums, over consecutive tiers, how far the strongest team of the
+// lower tier is rated above the weakest team of the tier above. It is zero
+// when the tiers are cleanly separated.
+func tierOverlap(teams []model.TeamPower, groupOf []int, rank []int) float64 {
+	lo := make([]float64, len(rank))
+	hi := make([]float64, len(rank))
+	seen := make([]bool, len(rank))
+	for i, team := range teams {
+		r, s := rank[groupOf[i]], strength(team)
+		if !seen[r] {
+			lo[r], hi[r], seen[r] = s, s, true
+			continue
+		}
+		lo[r], hi[r] = math.Min(lo[r], s), math.Max(hi[r], s)
+	}
+	overlap := 0.0
+	for r := 0; r+1 < len(rank); r++ {
+		if seen[r] && seen[r+1] {
+			overlap += math.Max(0, hi[r+1]-lo[r])
+		}
+	}
+	return overlap
+}
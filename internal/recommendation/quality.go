@@ -0,0 +1,225 @@
+package recommendation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// QualityOptions configures Evaluate.
+type QualityOptions struct {
+	// CloseMargin is the largest goal difference that counts as a close match.
+	CloseMargin int
+	// TopTeams is how many of the strongest teams the top spread looks at;
+	// zero means one per group.
+	TopTeams int
+}
+
+// DefaultQualityOptions returns the options used by the API.
+func DefaultQualityOptions() QualityOptions {
+	return QualityOptions{CloseMargin: 1}
+}
+
+// Quality scores a partition of teams into groups. Strength is the overall
+// power score; predictions come from the goal model.
+type Quality struct {
+	Groups []GroupQuality `json:"groups"`
+	// Mean and Variance describe the groups' average strength.
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Spread   float64 `json:"spread"`
+	// Competitiveness is the expected share of close matches over all
+	// pairings of the round robins, home and away.
+	Competitiveness float64 `json:"competitiveness"`
+	// ClubViolations counts pairs of teams of the same club in one group.
+	ClubViolations int       `json:"clubViolations"`
+	TopSpread      TopSpread `json:"topSpread"`
+}
+
+// GroupQuality scores one group.
+type GroupQuality struct {
+	Index int `json:"index"`
+	Teams int `json:"teams"`
+	// Mean and Variance describe the strength of the group's teams.
+	Mean            float64 `json:"mean"`
+	Variance        float64 `json:"variance"`
+	Competitiveness float64 `json:"competitiveness"`
+	ClubViolations  int     `json:"clubViolations"`
+	// TopTeams counts the group's teams among the strongest overall.
+	TopTeams int `json:"topTeams"`
+}
+
+// TopSpread reports how the strongest teams are spread over the groups. An
+// even spread puts at most MaxFair of them into one group.
+type TopSpread struct {
+	Teams         int  `json:"teams"`
+	GroupsCovered int  `json:"groupsCovered"`
+	MaxInGroup    int  `json:"maxInGroup"`
+	MaxFair       int  `json:"maxFair"`
+	Even          bool `json:"even"`
+}
+
+// PartitionModel fits the goal model Evaluate predicts pairings with: the
+// calibrated model of the league and indoor groups (see power.CalibratedModel)
+// with the margin handling of the metrics.
+func PartitionModel(league, indoor []model.GroupSnapshot, opts power.MetricOptions) power.GoalModel {
+	in := power.CalibrationInput{Model: power.DefaultGoalModelOptions()}
+	in.Model.Margin = opts.Margin
+	for _, snap := range league {
+		in.LeagueTeams = append(in.LeagueTeams, snap.Teams...)
+		in.LeagueMatches = append(in.LeagueMatches, snap.Matches...)
+	}
+	for _, snap := range indoor {
+		in.IndoorTeams = append(in.IndoorTeams, snap.Teams...)
+		in.IndoorMatches = append(in.IndoorMatches, snap.Matches...)
+	}
+	return power.CalibratedModel(in)
+}
+
+// Evaluate scores any assignment of teams to groups, be it the current groups
+// or a recommendation. Matches are predicted by goalModel, which has to rate
+// teams of different league groups on one scale, e.g. power.CalibratedModel;
+// a model fitted on league matches alone cannot compare them. A pairing
+// counts as close with the probability that the goal difference, taken as
+// normally distributed around the prediction with the model's Sigma, is at
+// most CloseMargin goals.
+func Evaluate(groups []Group, goalModel power.GoalModel, opts QualityOptions) Quality {
+	q := Quality{Groups: make([]GroupQuality, len(groups))}
+
+	var all []model.TeamPower
+	for _, group := range groups {
+		all = append(all, group.Teams...)
+	}
+	top := opts.TopTeams
+	if top <= 0 {
+		top = len(groups)
+	}
+	if top > len(all) {
+		top = len(all)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return strength(all[i]) > strength(all[j]) })
+	topIDs := make(map[string]bool, top)
+	for _, team := range all[:top] {
+		topIDs[team.Team.TeamID] = true
+	}
+
+	means := make([]float64, 0, len(groups))
+	closeSum, pairings := 0.0, 0
+	for g, group := range groups {
+		gq := GroupQuality{Index: group.Index, Teams: len(group.Teams)}
+		if gq.Index == 0 {
+			gq.Index = g + 1
+		}
+		values := make([]float64, len(group.Teams))
+		clubs := make(map[string]int)
+		for i, team := range group.Teams {
+			values[i] = strength(team)
+			if key := clubKey(team.Team); key != "" {
+				gq.ClubViolations += clubs[key]
+				clubs[key]++
+			}
+			if topIDs[team.Team.TeamID] {
+				gq.TopTeams++
+			}
+		}
+		gq.Mean, gq.Variance = meanVariance(values)
+
+		groupClose, groupPairings := 0.0, 0
+		for _, home := range group.Teams {
+			for _, away := range group.Teams {
+				if home.Team.TeamID == away.Team.TeamID {
+					continue
+				}
+				groupClose += closeProbability(goalModel.PredictDiff(home.Team.TeamID, away.Team.TeamID), goalModel.Sigma, opts.CloseMargin)
+				groupPairings++
+			}
+		}
+		if groupPairings > 0 {
+			gq.Competitiveness = groupClose / float64(groupPairings)
+		}
+		closeSum += groupClose
+		pairings += groupPairings
+
+		if len(group.Teams) > 0 {
+			means = append(means, gq.Mean)
+		}
+		q.ClubViolations += gq.ClubViolations
+		q.TopSpread.MaxInGroup = max(q.TopSpread.MaxInGroup, gq.TopTeams)
+		if gq.TopTeams > 0 {
+			q.TopSpread.GroupsCovered++
+		}
+		q.Groups[g] = gq
+	}
+
+	q.Mean, q.Variance = meanVariance(means)
+	q.Spread = spread(means)
+	if pairings > 0 {
+		q.Competitiveness = closeSum / float64(pairings)
+	}
+	q.TopSpread.Teams = top
+	if len(groups) > 0 {
+		q.TopSpread.MaxFair = (top + len(groups) - 1) / len(groups)
+	}
+	q.TopSpread.Even = q.TopSpread.MaxInGroup <= q.TopSpread.MaxFair
+	return q
+}
+
+// closeProbability returns the probability that a goal difference around
+// expected ends within margin goals, with a continuity correction of half a
+// goal. Without a spread, the match is close when the prediction is.
+func closeProbability(expected, sigma float64, margin int) float64 {
+	limit := float64(margin) + 0.5
+	if sigma <= 0 {
+		if math.Abs(expected) < limit {
+			return 1
+		}
+		return 0
+	}
+	cdf := func(x float64) float64 { return 0.5 * (1 + math.Erf((x-expected)/sigma/math.Sqrt2)) }
+	return cdf(limit) - cdf(-limit)
+}
+
+// CurrentGroups returns the teams in their current groups (TeamStats.GroupID),
+// in the order of configs. Teams of other groups are left out.
+func CurrentGroups(teams []model.TeamPower, configs []model.GroupConfig) []Group {
+	groups := make([]Group, len(configs))
+	index := make(map[string]int, len(configs))
+	for g, config := range configs {
+		groups[g] = Group{Index: g + 1, GroupID: config.ID, GroupName: config.Name, Teams: []model.TeamPower{}}
+		index[config.ID] = g
+	}
+	for _, team := range teams {
+		if g, ok := index[team.Team.GroupID]; ok {
+			groups[g].Teams = append(groups[g].Teams, team)
+		}
+	}
+	return groups
+}
+
+// Partition builds groups from lists of team IDs. Every team may appear once.
+func Partition(teams []model.TeamPower, assignment [][]string) ([]Group, error) {
+	byID := make(map[string]model.TeamPower, len(teams))
+	for _, team := range teams {
+		byID[team.Team.TeamID] = team
+	}
+	seen := make(map[string]int)
+	groups := make([]Group, len(assignment))
+	for g, ids := range assignment {
+		groups[g] = Group{Index: g + 1, Teams: make([]model.TeamPower, 0, len(ids))}
+		for _, id := range ids {
+			team, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("group %d: unknown team %q", g+1, id)
+			}
+			if other, ok := seen[id]; ok {
+				return nil, fmt.Errorf("team %q is in groups %d and %d", id, other, g+1)
+			}
+			seen[id] = g + 1
+			groups[g].Teams = append(groups[g].Teams, team)
+		}
+	}
+	return groups, nil
+}
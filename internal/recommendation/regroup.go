@@ -0,0 +1,76 @@
+package recommendation
+
+import (
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/history"
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/power"
+)
+
+// StrategyOptions holds the options of every strategy; a strategy only reads
+// its own.
+type StrategyOptions struct {
+	Balanced BalancedOptions
+	Tiered   TieredOptions
+	Minimal  MinimalOptions
+}
+
+// NewRegroup returns strategy as a Regroup for Stability: the tables of teams
+// are replayed from the matches, the teams rated across all groups with
+// metrics like the overall ranking and split into groups again. configs are
+// the current groups; snaps give the group metrics.
+func NewRegroup(strategy string, teams []model.TeamStats, snaps []model.GroupSnapshot, configs []model.GroupConfig, metrics power.MetricOptions, opts StrategyOptions) Regroup {
+	groupCount := max(len(configs), 1)
+	return func(matches []model.MatchResult) []Group {
+		replayed, kept := history.Replay(teams, matches, "")
+		ranked := RankTeams(replayed, kept, snaps, metrics)
+		switch strategy {
+		case StrategyTiered:
+			groups, _ := TieredGroups(ranked, opts.Tiered, opts.Balanced)
+			return groups
+		case StrategyMinimal:
+			return MinimalChange(ranked, configs, opts.Minimal).Groups
+		case StrategyBalanced:
+			groups, _ := BalancedGroups(ranked, groupCount, opts.Balanced)
+			return groups
+		default:
+			return SimpleBalancedGroups(ranked, groupCount)
+		}
+	}
+}
+
+// RankTeams rates teams across all groups and orders them strongest first, as
+// the strategies expect. The group metrics come from snaps.
+func RankTeams(teams []model.TeamStats, matches []model.MatchResult, snaps []model.GroupSnapshot, opts power.MetricOptions) []model.TeamPower {
+	overallMetrics := power.ComputeMetricsWithOptions(teams, matches, opts)
+	groupMetrics := make(map[string]map[string]model.MetricSet, len(snaps))
+	for _, snap := range snaps {
+		groupMetrics[snap.Config.ID] = power.ComputeMetricsWithOptions(snap.Teams, snap.Matches, opts)
+	}
+
+	teamPowers := make([]model.TeamPower, 0, len(teams))
+	for _, team := range teams {
+		teamPowers = append(teamPowers, model.TeamPower{
+			Team:           team,
+			GroupMetrics:   groupMetrics[team.GroupID][team.TeamID],
+			OverallMetrics: overallMetrics[team.TeamID],
+		})
+	}
+
+	sort.Slice(teamPowers, func(i, j int) bool {
+		pi := teamPowers[i].OverallMetrics.PowerScore
+		pj := teamPowers[j].OverallMetrics.PowerScore
+		if pi != pj {
+			return pi > pj
+		}
+		if teamPowers[i].Team.GoalDiff != teamPowers[j].Team.GoalDiff {
+			return teamPowers[i].Team.GoalDiff > teamPowers[j].Team.GoalDiff
+		}
+		if teamPowers[i].Team.Points != teamPowers[j].Team.Points {
+			return teamPowers[i].Team.Points > teamPowers[j].Team.Points
+		}
+		return teamPowers[i].Team.TeamName < teamPowers[j].Team.TeamName
+	})
+	return teamPowers
+}
@@ -9,28 +9,26 @@ import (
 
 // Group represents a simple grouping suggestion.
 type Group struct {
-	Index int               `json:"index"`
-	Teams []model.TeamPower `json:"teams"`
+	Index int `json:"index"`
+	// Tier is set by TieredGroups.
+	Tier int `json:"tier,omitempty"`
+	// GroupID and GroupName name the existing group, see MinimalChange.
+	GroupID   string            `json:"groupId,omitempty"`
+	GroupName string            `json:"groupName,omitempty"`
+	Teams     []model.TeamPower `json:"teams"`
 }
 
 // SimpleBalancedGroups splits sortedTeams into numGroups buckets, distributing
 // the remainder to the first buckets, and avoids placing teams from the same club
-// (detected via base name) into the same bucket when possible.
+// (by ClubID, or the base name when it is unset) into the same bucket when
+// possible. Buckets are filled in order, so the first gets the strongest teams:
+// this tiers the league rather than balancing it, see BalancedGroups.
 func SimpleBalancedGroups(sortedTeams []model.TeamPower, numGroups int) []Group {
 	if numGroups <= 0 {
 		numGroups = 1
 	}
 
-	total := len(sortedTeams)
-	targetSizes := make([]int, numGroups)
-	base := total / numGroups
-	remainder := total % numGroups
-	for i := range targetSizes {
-		targetSizes[i] = base
-		if i < remainder {
-			targetSizes[i]++
-		}
-	}
+	targetSizes := TargetSizes(len(sortedTeams), numGroups)
 
 	groupAssignments := make([][]model.TeamPower, numGroups)
 	clubSets := make([]map[string]struct{}, numGroups)
@@ -40,14 +38,14 @@ func SimpleBalancedGroups(sortedTeams []model.TeamPower, numGroups int) []Group
 
 	assignToGroup := func(team model.TeamPower, groupIdx int) {
 		groupAssignments[groupIdx] = append(groupAssignments[groupIdx], team)
-		key := baseClubKey(team.Team.TeamName)
+		key := clubKey(team.Team)
 		if key != "" {
 			clubSets[groupIdx][key] = struct{}{}
 		}
 	}
 
 	for _, team := range sortedTeams {
-		key := baseClubKey(team.Team.TeamName)
+		key := clubKey(team.Team)
 		placed := false
 
 		for idx := range groupAssignments {
@@ -87,6 +85,13 @@ func SimpleBalancedGroups(sortedTeams []model.TeamPower, numGroups int) []Group
 	return result
 }
 
+func clubKey(team model.TeamStats) string {
+	if team.ClubID != "" {
+		return team.ClubID
+	}
+	return baseClubKey(team.TeamName)
+}
+
 func baseClubKey(name string) string {
 	lower := strings.ToLower(strings.TrimSpace(name))
 	if lower == "" {
@@ -0,0 +1,328 @@
+package recommendation
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// StabilityOptions configures Stability.
+type StabilityOptions struct {
+	// Resamples is the number of bootstrap resamples.
+	Resamples int
+	Seed      int64
+	// Together is the share of resamples from which a team counts as stable
+	// in its group and a pair of teams as reliably together.
+	Together float64
+}
+
+// DefaultStabilityOptions returns the options used by the API.
+func DefaultStabilityOptions() StabilityOptions {
+	return StabilityOptions{Resamples: 200, Seed: 1, Together: 0.9}
+}
+
+// Validate reports options that cannot be run.
+func (o StabilityOptions) Validate() error {
+	// Every resample reruns the strategy, with travel the annealer, so
+	// requests stay well below a minute.
+	if o.Resamples < 1 || o.Resamples > 500 {
+		return errors.New("resamples must be between 1 and 500")
+	}
+	if o.Together <= 0 || o.Together > 1 {
+		return errors.New("together must be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// Regroup rebuilds a recommendation from resampled matches.
+type Regroup func(matches []model.MatchResult) []Group
+
+// StabilityReport describes how a recommendation holds up when the ratings are
+// recomputed from bootstrap resamples of the matches.
+type StabilityReport struct {
+	Resamples int     `json:"resamples"`
+	Seed      int64   `json:"seed"`
+	Together  float64 `json:"together"`
+	// MeanStability is the average share of resamples that keep a team in
+	// its group; StableTeams counts the teams kept in at least Together.
+	MeanStability float64          `json:"meanStability"`
+	StableTeams   int              `json:"stableTeams"`
+	Groups        []GroupStability `json:"groups"`
+	Teams         []TeamStability  `json:"teams"`
+	Pairs         []PairStability  `json:"pairs"`
+}
+
+// GroupStability summarizes the teams of one recommended group.
+type GroupStability struct {
+	Group         int     `json:"group"`
+	Tier          int     `json:"tier,omitempty"`
+	MeanStability float64 `json:"meanStability"`
+	// Core lists the teams kept in the group in at least Together of the
+	// resamples.
+	Core []string `json:"core"`
+}
+
+// TeamStability reports where a team ends up over the resamples.
+type TeamStability struct {
+	Team  model.TeamStats `json:"team"`
+	Group int             `json:"group"`
+	Tier  int             `json:"tier,omitempty"`
+	// Stability is the share of resamples that keep the team in Group.
+	Stability float64 `json:"stability"`
+	// Groups and Tiers give the share of resamples per group and tier, most
+	// frequent first.
+	Groups []GroupShare `json:"groups"`
+	Tiers  []TierShare  `json:"tiers,omitempty"`
+	// Rating is the team's power score over the resamples.
+	Rating Distribution `json:"rating"`
+}
+
+// GroupShare is the share of resamples that put a team into Group.
+type GroupShare struct {
+	Group int     `json:"group"`
+	Share float64 `json:"share"`
+}
+
+// TierShare is the share of resamples that put a team into Tier.
+type TierShare struct {
+	Tier  int     `json:"tier"`
+	Share float64 `json:"share"`
+}
+
+// PairStability is a pair of teams that share a group in at least Together of
+// the resamples.
+type PairStability struct {
+	TeamIDs   [2]string `json:"teamIds"`
+	TeamNames [2]string `json:"teamNames"`
+	Share     float64   `json:"share"`
+	// Group is the recommended group of the pair, zero when the
+	// recommendation splits it.
+	Group int `json:"group"`
+}
+
+// Stability resamples the played matches with replacement opts.Resamples
+// times, within each group so every group keeps its number of matches, and
+// lets regroup rebuild the recommendation from every resample. The groups of a
+// resample are matched to the recommended groups by their largest overlap, so
+// a group that only changes its number does not move its teams. The analysis
+// is deterministic for a given seed.
+func Stability(recommended []Group, matches []model.MatchResult, regroup Regroup, opts StabilityOptions) StabilityReport {
+	report := StabilityReport{Resamples: opts.Resamples, Seed: opts.Seed, Together: opts.Together, Groups: []GroupStability{}, Teams: []TeamStability{}, Pairs: []PairStability{}}
+
+	var teams []model.TeamPower
+	var groupOf, tierOf []int
+	for _, group := range recommended {
+		for _, team := range group.Teams {
+			teams = append(teams, team)
+			groupOf = append(groupOf, group.Index)
+			tierOf = append(tierOf, group.Tier)
+		}
+	}
+	index := make(map[string]int, len(teams))
+	for i, team := range teams {
+		index[team.Team.TeamID] = i
+	}
+
+	groupCounts := make([]map[int]int, len(teams))
+	tierCounts := make([]map[int]int, len(teams))
+	ratings := make([][]float64, len(teams))
+	for i := range teams {
+		groupCounts[i] = make(map[int]int)
+		tierCounts[i] = make(map[int]int)
+	}
+	together := make([][]int, len(teams))
+	for i := range together {
+		together[i] = make([]int, len(teams))
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for range opts.Resamples {
+		groups := regroup(Resample(matches, rng))
+		labels := alignGroups(recommended, groups)
+		for g, group := range groups {
+			members := make([]int, 0, len(group.Teams))
+			for _, team := range group.Teams {
+				i, ok := index[team.Team.TeamID]
+				if !ok {
+					continue
+				}
+				groupCounts[i][labels[g]]++
+				if group.Tier > 0 {
+					tierCounts[i][group.Tier]++
+				}
+				ratings[i] = append(ratings[i], strength(team))
+				members = append(members, i)
+			}
+			for a, i := range members {
+				for _, j := range members[a+1:] {
+					together[i][j]++
+					together[j][i]++
+				}
+			}
+		}
+	}
+
+	share := func(count int) float64 { return float64(count) / float64(opts.Resamples) }
+	byGroup := make(map[int]*GroupStability)
+	for _, group := range recommended {
+		report.Groups = append(report.Groups, GroupStability{Group: group.Index, Tier: group.Tier, Core: []string{}})
+	}
+	for g := range report.Groups {
+		byGroup[report.Groups[g].Group] = &report.Groups[g]
+	}
+	for i, team := range teams {
+		ts := TeamStability{
+			Team:      team.Team,
+			Group:     groupOf[i],
+			Tier:      tierOf[i],
+			Stability: share(groupCounts[i][groupOf[i]]),
+			Groups:    []GroupShare{},
+			Rating:    distribution(ratings[i]),
+		}
+		for group, count := range groupCounts[i] {
+			ts.Groups = append(ts.Groups, GroupShare{Group: group, Share: share(count)})
+		}
+		sort.Slice(ts.Groups, func(a, b int) bool {
+			if ts.Groups[a].Share != ts.Groups[b].Share {
+				return ts.Groups[a].Share > ts.Groups[b].Share
+			}
+			return ts.Groups[a].Group < ts.Groups[b].Group
+		})
+		for tier, count := range tierCounts[i] {
+			ts.Tiers = append(ts.Tiers, TierShare{Tier: tier, Share: share(count)})
+		}
+		sort.Slice(ts.Tiers, func(a, b int) bool {
+			if ts.Tiers[a].Share != ts.Tiers[b].Share {
+				return ts.Tiers[a].Share > ts.Tiers[b].Share
+			}
+			return ts.Tiers[a].Tier < ts.Tiers[b].Tier
+		})
+
+		report.MeanStability += ts.Stability / float64(len(teams))
+		group := byGroup[ts.Group]
+		group.MeanStability += ts.Stability
+		if ts.Stability >= opts.Together {
+			report.StableTeams++
+			group.Core = append(group.Core, team.Team.TeamName)
+		}
+		report.Teams = append(report.Teams, ts)
+
+		for j := i + 1; j < len(teams); j++ {
+			if share(together[i][j]) < opts.Together {
+				continue
+			}
+			pair := PairStability{
+				TeamIDs:   [2]string{team.Team.TeamID, teams[j].Team.TeamID},
+				TeamNames: [2]string{team.Team.TeamName, teams[j].Team.TeamName},
+				Share:     share(together[i][j]),
+			}
+			if groupOf[i] == groupOf[j] {
+				pair.Group = groupOf[i]
+			}
+			report.Pairs = append(report.Pairs, pair)
+		}
+	}
+	for g, group := range recommended {
+		if len(group.Teams) > 0 {
+			report.Groups[g].MeanStability /= float64(len(group.Teams))
+		}
+	}
+	sort.SliceStable(report.Pairs, func(a, b int) bool { return report.Pairs[a].Share > report.Pairs[b].Share })
+	return report
+}
+
+// Resample draws the played matches of every group with replacement, as many
+// as the group has, and keeps them in their original order.
+func Resample(matches []model.MatchResult, rng *rand.Rand) []model.MatchResult {
+	var order []string
+	byGroup := make(map[string][]int)
+	for i, m := range matches {
+		if !m.Played() {
+			continue
+		}
+		if _, ok := byGroup[m.GroupID]; !ok {
+			order = append(order, m.GroupID)
+		}
+		byGroup[m.GroupID] = append(byGroup[m.GroupID], i)
+	}
+	var picks []int
+	for _, groupID := range order {
+		played := byGroup[groupID]
+		for range played {
+			picks = append(picks, played[rng.Intn(len(played))])
+		}
+	}
+	sort.Ints(picks)
+	out := make([]model.MatchResult, len(picks))
+	for i, pick := range picks {
+		out[i] = matches[pick]
+	}
+	return out
+}
+
+// alignGroups labels every group of a resample with the index of the
+// recommended group it shares the most teams with, taking the largest
+// overlaps first. Groups left over take the recommended indices nobody took,
+// in order, so no two groups share a label.
+func alignGroups(recommended, groups []Group) []int {
+	home := make(map[string]int)
+	for r, group := range recommended {
+		for _, team := range group.Teams {
+			home[team.Team.TeamID] = r
+		}
+	}
+	type overlap struct{ g, r, n int }
+	var overlaps []overlap
+	for g, group := range groups {
+		counts := make(map[int]int)
+		for _, team := range group.Teams {
+			if r, ok := home[team.Team.TeamID]; ok {
+				counts[r]++
+			}
+		}
+		for r, n := range counts {
+			overlaps = append(overlaps, overlap{g, r, n})
+		}
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		if overlaps[i].n != overlaps[j].n {
+			return overlaps[i].n > overlaps[j].n
+		}
+		if overlaps[i].g != overlaps[j].g {
+			return overlaps[i].g < overlaps[j].g
+		}
+		return overlaps[i].r < overlaps[j].r
+	})
+
+	labels := make([]int, len(groups))
+	taken := make([]bool, len(recommended))
+	for _, o := range overlaps {
+		if labels[o.g] != 0 || taken[o.r] {
+			continue
+		}
+		labels[o.g] = recommended[o.r].Index
+		taken[o.r] = true
+	}
+	var unused []int
+	next := 1
+	for r, group := range recommended {
+		if !taken[r] {
+			unused = append(unused, group.Index)
+		}
+		next = max(next, group.Index+1)
+	}
+	for g := range groups {
+		if labels[g] != 0 {
+			continue
+		}
+		if len(unused) > 0 {
+			labels[g], unused = unused[0], unused[1:]
+			continue
+		}
+		labels[g] = next
+		next++
+	}
+	return labels
+}
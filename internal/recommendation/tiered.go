@@ -0,0 +1,102 @@
+package recommendation
+
+import (
+	"errors"
+
+	"github.com/schlubbi/score_board/internal/model"
+)
+
+// TieredOptions configures TieredGroups.
+type TieredOptions struct {
+	Tiers         int
+	GroupsPerTier int
+}
+
+// DefaultTieredOptions splits groupCount groups into two tiers.
+func DefaultTieredOptions(groupCount int) TieredOptions {
+	perTier := groupCount / 2
+	if perTier < 1 {
+		perTier = 1
+	}
+	return TieredOptions{Tiers: 2, GroupsPerTier: perTier}
+}
+
+// Validate reports options that cannot produce groups.
+func (o TieredOptions) Validate(teams int) error {
+	if o.Tiers < 1 || o.GroupsPerTier < 1 {
+		return errors.New("tiers and groupsPerTier must be positive")
+	}
+	if o.Tiers*o.GroupsPerTier > teams {
+		return errors.New("more groups than teams")
+	}
+	return nil
+}
+
+// Tier describes one strength tier. Ratings are overall power scores.
+type Tier struct {
+	Tier   int   `json:"tier"`
+	Groups []int `json:"groups"`
+	Teams  int   `json:"teams"`
+	// MaxRating and MinRating are the ratings of the tier's strongest and
+	// weakest team.
+	MaxRating  float64 `json:"maxRating"`
+	MinRating  float64 `json:"minRating"`
+	MeanRating float64 `json:"meanRating"`
+	// Cutoff is the rating between this tier's weakest team and the next
+	// tier's strongest; Gap is the distance between the two. Both are zero
+	// for the last tier.
+	Cutoff float64 `json:"cutoff"`
+	Gap    float64 `json:"gap"`
+	// Objective measures how even the tier's groups are.
+	Objective Objective `json:"objective"`
+}
+
+// TieredGroups splits sortedTeams into strength tiers, strongest first, and
+// each tier into balanced groups with BalancedGroups, so club separation holds
+// within every group. Tiers are sized so that all groups end up with the same
+// target sizes as SimpleBalancedGroups. Groups are numbered across tiers.
+func TieredGroups(sortedTeams []model.TeamPower, opts TieredOptions, balanced BalancedOptions) ([]Group, []Tier) {
+	if opts.Tiers < 1 {
+		opts.Tiers = 1
+	}
+	if opts.GroupsPerTier < 1 {
+		opts.GroupsPerTier = 1
+	}
+	sizes := TargetSizes(len(sortedTeams), opts.Tiers*opts.GroupsPerTier)
+
+	groups := make([]Group, 0, len(sizes))
+	tiers := make([]Tier, 0, opts.Tiers)
+	start := 0
+	for t := 0; t < opts.Tiers; t++ {
+		count := 0
+		for _, size := range sizes[t*opts.GroupsPerTier : (t+1)*opts.GroupsPerTier] {
+			count += size
+		}
+		members := sortedTeams[start : start+count]
+		start += count
+
+		tierGroups, objective := BalancedGroups(members, opts.GroupsPerTier, balanced)
+		tier := Tier{Tier: t + 1, Groups: []int{}, Teams: len(members), Objective: objective}
+		for _, group := range tierGroups {
+			group.Index = len(groups) + 1
+			group.Tier = t + 1
+			groups = append(groups, group)
+			tier.Groups = append(tier.Groups, group.Index)
+		}
+		if len(members) > 0 {
+			tier.MaxRating = strength(members[0])
+			tier.MinRating = strength(members[len(members)-1])
+			for _, team := range members {
+				tier.MeanRating += strength(team)
+			}
+			tier.MeanRating /= float64(len(members))
+			if start < len(sortedTeams) {
+				next := strength(sortedTeams[start])
+				tier.Cutoff = (tier.MinRating + next) / 2
+				tier.Gap = tier.MinRating - next
+			}
+		}
+		tiers = append(tiers, tier)
+	}
+	return groups, tiers
+}
@@ -96,6 +96,18 @@ func (r *Repository) AllTeams() []model.TeamStats {
 	return teams
 }
 
+// AllMatches flattens all group snapshots into a slice of matches.
+func (r *Repository) AllMatches() []model.MatchResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matches := make([]model.MatchResult, 0)
+	for _, snap := range r.groups {
+		matches = append(matches, snap.Matches...)
+	}
+	return matches
+}
+
 // LastUpdated returns the latest scrape timestamp.
 func (r *Repository) LastUpdated() time.Time {
 	r.mu.RLock()
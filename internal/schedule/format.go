@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteCSV writes one row per fixture, ordered by date.
+func WriteCSV(w io.Writer, s Schedule) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"date", "group", "round", "home_id", "home", "away_id", "away"}); err != nil {
+		return err
+	}
+	for _, f := range s.Fixtures() {
+		if err := cw.Write([]string{f.Date, f.Group, strconv.Itoa(f.Round), f.HomeID, f.Home, f.AwayID, f.Away}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteICal writes the fixtures as all-day events of an iCalendar (RFC 5545)
+// named name. UIDs stay the same as long as the pairing and round do.
+func WriteICal(w io.Writer, s Schedule, name string, now time.Time) error {
+	stamp := now.UTC().Format("20060102T150405Z")
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//score_board//schedule//DE",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + icalText(name),
+	}
+	for _, f := range s.Fixtures() {
+		day, err := time.Parse(DateLayout, f.Date)
+		if err != nil {
+			return err
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%s-%d-%s-%s@score_board", icalID(f.Group), f.Round, icalID(f.HomeID), icalID(f.AwayID)),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+day.Format("20060102"),
+			"DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+icalText(f.Home+" - "+f.Away),
+			"DESCRIPTION:"+icalText(fmt.Sprintf("%s, round %d", f.Group, f.Round)),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(fold(line))
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// icalText escapes a TEXT value.
+func icalText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// icalID keeps the characters of value that are safe in a UID.
+func icalID(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, value)
+}
+
+// fold splits a content line into lines of at most 75 octets, continuing with
+// a leading space, without cutting a UTF-8 sequence.
+func fold(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,549 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/recommendation"
+)
+
+// DateLayout is the layout of all dates in a schedule.
+const DateLayout = "2006-01-02"
+
+// Options configures Generate.
+type Options struct {
+	// Start is the first matchday.
+	Start time.Time
+	// IntervalDays is the number of days between matchdays.
+	IntervalDays int
+	// Blackouts are dates without matches; their matchday moves on by
+	// IntervalDays.
+	Blackouts []time.Time
+	// Double plays every pairing twice, the second half with home and away
+	// swapped.
+	Double bool
+	// Pitches is the number of pitches per club, keyed by club ID (or club
+	// name for teams without one). Clubs not listed have DefaultPitches.
+	Pitches        map[string]int
+	DefaultPitches int
+}
+
+// DefaultOptions returns the options used by the API: weekly matchdays from
+// the next Saturday after now, single round robin, one pitch per club.
+func DefaultOptions(now time.Time) Options {
+	start := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	for start.Weekday() != time.Saturday {
+		start = start.AddDate(0, 0, 1)
+	}
+	return Options{Start: start, IntervalDays: 7, DefaultPitches: 1}
+}
+
+// ParseDates parses a comma-separated list of dates in DateLayout.
+func ParseDates(value string) ([]time.Time, error) {
+	var dates []time.Time
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		date, err := time.Parse(DateLayout, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", raw)
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
+
+// Group is a group to schedule.
+type Group struct {
+	Name  string
+	Teams []model.TeamStats
+}
+
+// FromRecommendation names the groups of a recommendation for scheduling: the
+// existing group's name where there is one, "Group n" otherwise.
+func FromRecommendation(groups []recommendation.Group) []Group {
+	out := make([]Group, len(groups))
+	for g, group := range groups {
+		name := group.GroupName
+		if name == "" {
+			name = fmt.Sprintf("Group %d", group.Index)
+		}
+		out[g] = Group{Name: name, Teams: make([]model.TeamStats, len(group.Teams))}
+		for t, team := range group.Teams {
+			out[g].Teams[t] = team.Team
+		}
+	}
+	return out
+}
+
+// Fixture is one scheduled match.
+type Fixture struct {
+	Group  string `json:"group"`
+	Round  int    `json:"round"`
+	Date   string `json:"date"`
+	HomeID string `json:"homeId"`
+	Home   string `json:"home"`
+	AwayID string `json:"awayId"`
+	Away   string `json:"away"`
+}
+
+// Round is one matchday of a group.
+type Round struct {
+	Round    int       `json:"round"`
+	Date     string    `json:"date"`
+	Fixtures []Fixture `json:"fixtures"`
+	// Bye is the team without a match, in groups with an odd team count.
+	Bye string `json:"bye,omitempty"`
+}
+
+// TeamBalance counts a team's home and away games. A break is two home or two
+// away games in a row.
+type TeamBalance struct {
+	TeamID string `json:"teamId"`
+	Team   string `json:"team"`
+	Home   int    `json:"home"`
+	Away   int    `json:"away"`
+	Breaks int    `json:"breaks"`
+}
+
+// GroupSchedule is the schedule of one group.
+type GroupSchedule struct {
+	Group   string        `json:"group"`
+	Rounds  []Round       `json:"rounds"`
+	Balance []TeamBalance `json:"balance"`
+}
+
+// Clash is a date on which a club has more home games than pitches, which
+// Generate could not resolve.
+type Clash struct {
+	Date     string    `json:"date"`
+	Club     string    `json:"club"`
+	Pitches  int       `json:"pitches"`
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// Schedule is the output of Generate.
+type Schedule struct {
+	Double bool `json:"double"`
+	// Matchdays are the dates of the rounds; round i of every group is
+	// played on Matchdays[i-1].
+	Matchdays []string        `json:"matchdays"`
+	Blackouts []string        `json:"blackouts"`
+	Groups    []GroupSchedule `json:"groups"`
+	// Flipped counts the pairings whose home side changed to avoid clashes.
+	Flipped int     `json:"flipped"`
+	Clashes []Clash `json:"clashes"`
+}
+
+// Fixtures returns all fixtures ordered by date and group.
+func (s Schedule) Fixtures() []Fixture {
+	var fixtures []Fixture
+	for _, group := range s.Groups {
+		for _, round := range group.Rounds {
+			fixtures = append(fixtures, round.Fixtures...)
+		}
+	}
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		return fixtures[i].Date < fixtures[j].Date
+	})
+	return fixtures
+}
+
+// pairing is one match of the circle, by index into the group's teams.
+type pairing struct {
+	round      int
+	home, away int
+}
+
+// Generate builds a round-robin schedule for every group with the circle
+// method: one team stays fixed while the others rotate, and home rights
+// alternate so no team has more than one home game more than away games.
+// Round i of every group falls on the i-th matchday, counting IntervalDays
+// from Start and skipping blackout dates. When a club has more home games on
+// a date than pitches, the home side of one of its pairings is swapped (both
+// legs in a double round robin) if the opponent's club has a pitch free;
+// clashes that remain are reported.
+func Generate(groups []Group, opts Options) (Schedule, error) {
+	if opts.Start.IsZero() {
+		return Schedule{}, errors.New("start date is required")
+	}
+	if opts.IntervalDays <= 0 {
+		return Schedule{}, errors.New("interval must be at least one day")
+	}
+
+	circles := make([][]pairing, len(groups))
+	rounds := 0
+	for g, group := range groups {
+		circles[g] = circle(len(group.Teams), opts.Double)
+		rounds = max(rounds, roundCount(len(group.Teams), opts.Double))
+	}
+
+	blackout := make(map[string]bool, len(opts.Blackouts))
+	s := Schedule{Double: opts.Double, Matchdays: []string{}, Blackouts: []string{}, Groups: []GroupSchedule{}, Clashes: []Clash{}}
+	for _, date := range opts.Blackouts {
+		key := date.Format(DateLayout)
+		if !blackout[key] {
+			blackout[key] = true
+			s.Blackouts = append(s.Blackouts, key)
+		}
+	}
+	sort.Strings(s.Blackouts)
+	date := opts.Start
+	for len(s.Matchdays) < rounds {
+		if key := date.Format(DateLayout); !blackout[key] {
+			s.Matchdays = append(s.Matchdays, key)
+		}
+		date = date.AddDate(0, 0, opts.IntervalDays)
+	}
+
+	groups = arrangeSlots(groups, circles, opts)
+	s.Flipped = resolveClashes(groups, circles, opts)
+
+	for g, group := range groups {
+		gs := GroupSchedule{Group: group.Name, Rounds: []Round{}}
+		perRound := roundCount(len(group.Teams), opts.Double)
+		playing := make([]map[int]bool, perRound)
+		for r := range perRound {
+			gs.Rounds = append(gs.Rounds, Round{Round: r + 1, Date: s.Matchdays[r], Fixtures: []Fixture{}})
+			playing[r] = make(map[int]bool)
+		}
+		for _, p := range circles[g] {
+			gs.Rounds[p.round].Fixtures = append(gs.Rounds[p.round].Fixtures, fixture(group, p, s.Matchdays[p.round]))
+			playing[p.round][p.home], playing[p.round][p.away] = true, true
+		}
+		if len(group.Teams)%2 == 1 {
+			for r := range gs.Rounds {
+				for t, team := range group.Teams {
+					if !playing[r][t] {
+						gs.Rounds[r].Bye = team.TeamName
+					}
+				}
+			}
+		}
+		gs.Balance = balance(group, circles[g])
+		s.Groups = append(s.Groups, gs)
+	}
+
+	for _, clash := range clashes(groups, circles, opts) {
+		c := Clash{Date: s.Matchdays[clash.round], Club: clash.club, Pitches: clash.pitches, Fixtures: []Fixture{}}
+		for _, ref := range clash.refs {
+			c.Fixtures = append(c.Fixtures, fixture(groups[ref.group], circles[ref.group][ref.index], c.Date))
+		}
+		s.Clashes = append(s.Clashes, c)
+	}
+	return s, nil
+}
+
+func roundCount(teams int, double bool) int {
+	if teams < 2 {
+		return 0
+	}
+	rounds := teams - 1
+	if teams%2 == 1 {
+		rounds = teams
+	}
+	if double {
+		rounds *= 2
+	}
+	return rounds
+}
+
+// circle returns the pairings of a round robin over n teams. An odd team count
+// gets a ghost team; whoever meets it has a bye.
+func circle(n int, double bool) []pairing {
+	if n < 2 {
+		return nil
+	}
+	size := n
+	if size%2 == 1 {
+		size++
+	}
+	fixed := size - 1
+	var pairings []pairing
+	for r := 0; r < size-1; r++ {
+		// The fixed team alternates home and away; the other pairings
+		// alternate with their distance from it, so home rights rotate.
+		a, b := r, fixed
+		if r%2 == 1 {
+			a, b = b, a
+		}
+		pairings = append(pairings, pairing{round: r, home: a, away: b})
+		for i := 1; i < size/2; i++ {
+			a := (r + i) % (size - 1)
+			b := (r - i + size - 1) % (size - 1)
+			if i%2 == 1 {
+				a, b = b, a
+			}
+			pairings = append(pairings, pairing{round: r, home: a, away: b})
+		}
+	}
+
+	// Drop the ghost team's pairings.
+	kept := pairings[:0]
+	for _, p := range pairings {
+		if p.home < n && p.away < n {
+			kept = append(kept, p)
+		}
+	}
+	if double {
+		rounds := size - 1
+		for _, p := range kept[:len(kept):len(kept)] {
+			kept = append(kept, pairing{round: p.round + rounds, home: p.away, away: p.home})
+		}
+	}
+	return kept
+}
+
+// Pairing is a match of a round robin between two team positions.
+type Pairing struct {
+	Round int `json:"round"`
+	Home  int `json:"home"`
+	Away  int `json:"away"`
+}
+
+// RoundRobin returns the pairings of a round robin over n teams by the circle
+// method, with the home rights Generate uses. Rounds count from zero.
+func RoundRobin(n int, double bool) []Pairing {
+	pairings := circle(n, double)
+	out := make([]Pairing, len(pairings))
+	for i, p := range pairings {
+		out[i] = Pairing{Round: p.round, Home: p.home, Away: p.away}
+	}
+	return out
+}
+
+// fixtureRef points at a pairing of a group.
+type fixtureRef struct {
+	group, index int
+}
+
+type clash struct {
+	round   int
+	club    string
+	pitches int
+	refs    []fixtureRef
+}
+
+// homeGames indexes the home pairings by round and club.
+func homeGames(groups []Group, circles [][]pairing) map[int]map[string][]fixtureRef {
+	games := make(map[int]map[string][]fixtureRef)
+	for g, group := range groups {
+		for i, p := range circles[g] {
+			if games[p.round] == nil {
+				games[p.round] = make(map[string][]fixtureRef)
+			}
+			key := ClubKey(group.Teams[p.home])
+			games[p.round][key] = append(games[p.round][key], fixtureRef{group: g, index: i})
+		}
+	}
+	return games
+}
+
+func clashes(groups []Group, circles [][]pairing, opts Options) []clash {
+	var out []clash
+	games := homeGames(groups, circles)
+	rounds := make([]int, 0, len(games))
+	for round := range games {
+		rounds = append(rounds, round)
+	}
+	sort.Ints(rounds)
+	for _, round := range rounds {
+		keys := make([]string, 0, len(games[round]))
+		for key := range games[round] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			refs := games[round][key]
+			if pitches := opts.pitches(key); len(refs) > pitches {
+				out = append(out, clash{round: round, club: key, pitches: pitches, refs: refs})
+			}
+		}
+	}
+	return out
+}
+
+// excess counts the home games beyond the clubs' pitches over all dates.
+func excess(groups []Group, circles [][]pairing, opts Options) int {
+	total := 0
+	for _, c := range clashes(groups, circles, opts) {
+		total += len(c.refs) - c.pitches
+	}
+	return total
+}
+
+// arrangeSlots decides which team takes which position of its group's circle.
+// The position fixes a team's home and away pattern, so teams of one club in
+// different groups should get complementary ones, like the key numbers of a
+// federation schedule. It swaps positions within groups while that lowers
+// the clashes and returns groups with the teams reordered.
+func arrangeSlots(groups []Group, circles [][]pairing, opts Options) []Group {
+	arranged := make([]Group, len(groups))
+	for g, group := range groups {
+		arranged[g] = Group{Name: group.Name, Teams: append([]model.TeamStats(nil), group.Teams...)}
+	}
+	best := excess(arranged, circles, opts)
+	for pass := 0; pass < maxArrangePasses && best > 0; pass++ {
+		improved := false
+		for _, group := range arranged {
+			for i := range group.Teams {
+				for j := i + 1; j < len(group.Teams); j++ {
+					group.Teams[i], group.Teams[j] = group.Teams[j], group.Teams[i]
+					if cost := excess(arranged, circles, opts); cost < best {
+						best, improved = cost, true
+						continue
+					}
+					group.Teams[i], group.Teams[j] = group.Teams[j], group.Teams[i]
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return arranged
+}
+
+const maxArrangePasses = 20
+
+// resolveClashes swaps home rights of clashing pairings where the opponent's
+// club has room, and returns the number of swaps.
+func resolveClashes(groups []Group, circles [][]pairing, opts Options) int {
+	flipped := 0
+	for _, c := range clashes(groups, circles, opts) {
+		games := homeGames(groups, circles)
+		excess := len(games[c.round][c.club]) - c.pitches
+		for _, ref := range c.refs {
+			if excess <= 0 {
+				break
+			}
+			legs := []int{ref.index}
+			if opts.Double {
+				legs = append(legs, mirror(circles[ref.group], ref.index))
+			}
+			if canFlip(groups[ref.group], circles[ref.group], legs, games, opts) {
+				for _, leg := range legs {
+					p := &circles[ref.group][leg]
+					p.home, p.away = p.away, p.home
+				}
+				games = homeGames(groups, circles)
+				flipped++
+				excess--
+			}
+		}
+	}
+	return flipped
+}
+
+// mirror returns the other leg of the pairing at index in a double round robin.
+func mirror(pairings []pairing, index int) int {
+	p := pairings[index]
+	for i, q := range pairings {
+		if i != index && q.home == p.away && q.away == p.home {
+			return i
+		}
+	}
+	return index
+}
+
+// canFlip reports whether swapping home and away of the legs keeps every
+// affected club within its pitches and, in a single round robin, keeps both
+// teams' home and away games within one of each other.
+func canFlip(group Group, pairings []pairing, legs []int, games map[int]map[string][]fixtureRef, opts Options) bool {
+	for _, leg := range legs {
+		p := pairings[leg]
+		newHome := ClubKey(group.Teams[p.away])
+		oldHome := ClubKey(group.Teams[p.home])
+		if newHome == oldHome || len(games[p.round][newHome])+1 > opts.pitches(newHome) {
+			return false
+		}
+	}
+	if len(legs) == 1 {
+		p := pairings[legs[0]]
+		home := make(map[int]int)
+		for _, q := range pairings {
+			home[q.home]++
+			home[q.away]--
+		}
+		// After the swap, p.away gains a home game and p.home loses one.
+		if abs(home[p.away]+2) > 1 || abs(home[p.home]-2) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (o Options) pitches(club string) int {
+	if n, ok := o.Pitches[club]; ok {
+		return n
+	}
+	if o.DefaultPitches > 0 {
+		return o.DefaultPitches
+	}
+	return 1
+}
+
+// ClubKey returns the key a team's club is looked up by in Options.Pitches.
+func ClubKey(team model.TeamStats) string {
+	if team.ClubID != "" {
+		return team.ClubID
+	}
+	return model.ClubName(team.TeamName)
+}
+
+func fixture(group Group, p pairing, date string) Fixture {
+	home, away := group.Teams[p.home], group.Teams[p.away]
+	return Fixture{
+		Group:  group.Name,
+		Round:  p.round + 1,
+		Date:   date,
+		HomeID: home.TeamID,
+		Home:   home.TeamName,
+		AwayID: away.TeamID,
+		Away:   away.TeamName,
+	}
+}
+
+func balance(group Group, pairings []pairing) []TeamBalance {
+	out := make([]TeamBalance, len(group.Teams))
+	venues := make([]map[int]bool, len(group.Teams))
+	for t, team := range group.Teams {
+		out[t] = TeamBalance{TeamID: team.TeamID, Team: team.TeamName}
+		venues[t] = make(map[int]bool)
+	}
+	rounds := 0
+	for _, p := range pairings {
+		out[p.home].Home++
+		out[p.away].Away++
+		venues[p.home][p.round] = true
+		venues[p.away][p.round] = false
+		rounds = max(rounds, p.round+1)
+	}
+	for t := range out {
+		last, seen := false, false
+		for r := 0; r < rounds; r++ {
+			home, ok := venues[t][r]
+			if !ok {
+				continue
+			}
+			if seen && home == last {
+				out[t].Breaks++
+			}
+			last, seen = home, true
+		}
+	}
+	return out
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
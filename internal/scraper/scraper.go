@@ -118,6 +118,7 @@ func extractTeam(sel *goquery.Selection, cfg model.GroupConfig) (model.TeamStats
 		TeamID:       teamID,
 		TeamName:     name,
 		LogoURL:      logoURL,
+		ClubID:       model.ClubIDFromLogoURL(logoURL),
 		Rank:         rank,
 		Games:        games,
 		Wins:         wins,
@@ -336,7 +337,14 @@ var (
 	matchDateRegex  = regexp.MustCompile(`/spieldatum/(\d{4}-\d{2}-\d{2})/`)
 )
 
-// EnrichMatchMetadata loads the match pages and tries to extract matchday + match date.
+// HasMatchMetadata reports whether the match page of m was already read. The
+// date, matchday and venue only come from that page, so once it gave a date or
+// a matchday it is not loaded again, even when the rest is missing there.
+func HasMatchMetadata(m model.MatchResult) bool {
+	return m.MatchDate != "" || m.MatchdayTag != ""
+}
+
+// EnrichMatchMetadata loads the match pages and tries to extract matchday, match date and venue.
 // This is intentionally best-effort (network hiccups should not fail the overall scrape).
 func (s *Scraper) EnrichMatchMetadata(ctx context.Context, matches []model.MatchResult) []model.MatchResult {
 	out := make([]model.MatchResult, len(matches))
@@ -347,7 +355,7 @@ func (s *Scraper) EnrichMatchMetadata(ctx context.Context, matches []model.Match
 		if m.URL == "" {
 			continue
 		}
-		if m.MatchDate != "" && m.MatchdayTag != "" {
+		if HasMatchMetadata(m) {
 			continue
 		}
 
@@ -390,6 +398,11 @@ func (s *Scraper) EnrichMatchMetadata(ctx context.Context, matches []model.Match
 			})
 		}
 
+		if m.Venue == "" {
+			// The venue links to a map, e.g. "Kunstrasenplatz, Sportpark Musterstadt, Musterweg 1, 34225 Baunatal".
+			m.Venue = strings.Join(strings.Fields(doc.Find("a.location").First().Text()), " ")
+		}
+
 		out[i] = m
 	}
 
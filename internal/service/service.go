@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/schlubbi/score_board/internal/model"
 	"github.com/schlubbi/score_board/internal/repository"
@@ -19,6 +20,11 @@ type Service struct {
 
 	indoorRepo            *repository.Repository
 	indoorTournamentStaffel string
+
+	// metadata caches the date, matchday and venue of played league matches
+	// by match ID, so a refresh only loads the match pages of new results.
+	metadataMu sync.Mutex
+	metadata   map[string]model.MatchResult
 }
 
 // New creates a Service instance.
@@ -34,6 +40,7 @@ func New(scraper *scraper.Scraper, repo *repository.Repository, groups []model.G
 		configByID:             cfgByID,
 		indoorRepo:             indoorRepo,
 		indoorTournamentStaffel: indoorTournamentStaffel,
+		metadata:               make(map[string]model.MatchResult),
 	}
 }
 
@@ -45,6 +52,7 @@ func (s *Service) Refresh(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("fetch %s: %w", cfg.ID, err)
 		}
+		snap.Matches = s.enrichMatches(ctx, snap.Matches)
 		snapshots = append(snapshots, snap)
 	}
 
@@ -52,6 +60,35 @@ func (s *Service) Refresh(ctx context.Context) error {
 	return nil
 }
 
+// enrichMatches adds the match date, matchday and venue from the match pages,
+// which the cross table does not show. Dates order the season for Elo,
+// Glicko-2 periods, form and rank movements. It is best-effort: matches whose
+// page fails to load keep their metadata empty.
+func (s *Service) enrichMatches(ctx context.Context, matches []model.MatchResult) []model.MatchResult {
+	s.metadataMu.Lock()
+	for i, m := range matches {
+		if cached, ok := s.metadata[m.ID]; ok {
+			matches[i].MatchDate = cached.MatchDate
+			matches[i].MatchdayTag = cached.MatchdayTag
+			matches[i].Venue = cached.Venue
+		}
+	}
+	s.metadataMu.Unlock()
+
+	// The pages are loaded without the lock; matches the cache filled in are
+	// skipped by the scraper.
+	matches = s.scraper.EnrichMatchMetadata(ctx, matches)
+
+	s.metadataMu.Lock()
+	defer s.metadataMu.Unlock()
+	for _, m := range matches {
+		if m.ID != "" && m.Played() && scraper.HasMatchMetadata(m) {
+			s.metadata[m.ID] = m
+		}
+	}
+	return matches
+}
+
 // RefreshIndoor discovers all tournament groups behind the expandable headers and scrapes them.
 func (s *Service) RefreshIndoor(ctx context.Context) error {
 	if s.indoorRepo == nil || s.indoorTournamentStaffel == "" {
@@ -69,6 +106,10 @@ func (s *Service) RefreshIndoor(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("fetch indoor %s: %w", cfg.ID, err)
 		}
+		// Tournament games are played at a single venue.
+		for i := range snap.Matches {
+			snap.Matches[i].Neutral = true
+		}
 		snapshots = append(snapshots, snap)
 	}
 
@@ -87,6 +128,7 @@ func (s *Service) RefreshGroup(ctx context.Context, groupID string) (model.Group
 	if err != nil {
 		return model.GroupSnapshot{}, err
 	}
+	snap.Matches = s.enrichMatches(ctx, snap.Matches)
 
 	s.repo.Upsert(snap)
 	return snap, nil
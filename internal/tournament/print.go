@@ -0,0 +1,49 @@
+package tournament
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteText writes a printable schedule: the groups, then one line per match
+// with room for the score.
+func WriteText(w io.Writer, plan Plan, title string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n\n", title, strings.Repeat("=", len([]rune(title))))
+	fmt.Fprintf(&b, "%s, %d pitch(es), %d min + %d min break, %d slots, end %s\n\n",
+		plan.Options.Start.Format("02.01.2006 15:04"), plan.Options.Pitches, plan.Options.GameMinutes, plan.Options.BreakMinutes, plan.Slots, plan.End.Format("15:04"))
+	for _, group := range plan.Groups {
+		fmt.Fprintf(&b, "%s\n", group.GroupName)
+		for _, team := range group.Teams {
+			fmt.Fprintf(&b, "  %s\n", team.Team.TeamName)
+		}
+		b.WriteString("\n")
+	}
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Time\tPitch\tNo.\tRound\tHome\t\tAway\tScore")
+	lastStage := ""
+	for _, m := range plan.Matches {
+		if lastStage == StageGroup && m.Stage != StageGroup {
+			fmt.Fprintln(tw, "\t\t\t\t\t\t\t")
+		}
+		lastStage = m.Stage
+		mark := ""
+		if m.BackToBack {
+			mark = " *"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t-\t%s\t___ : ___%s\n", m.Kickoff.Format("15:04"), m.Pitch, m.ID, m.Round, m.Home.Label(), m.Away.Label(), mark)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(plan.Warnings) > 0 {
+		b.WriteString("\n* ")
+		b.WriteString(strings.Join(plan.Warnings, "\n* "))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,425 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schlubbi/score_board/internal/model"
+	"github.com/schlubbi/score_board/internal/recommendation"
+	"github.com/schlubbi/score_board/internal/schedule"
+)
+
+// Bracket formats after the group stage.
+const (
+	// BracketKnockout plays the best teams of every group down to a final,
+	// with a match for third place.
+	BracketKnockout = "knockout"
+	// BracketPlacement lets the teams of the same rank in the two groups play
+	// for every place.
+	BracketPlacement = "placement"
+)
+
+// Stages of a match.
+const (
+	StageGroup     = "group"
+	StageKnockout  = "knockout"
+	StagePlacement = "placement"
+)
+
+// Options configures New.
+type Options struct {
+	Groups  int
+	Pitches int
+	// Start is the kickoff of the first slot.
+	Start time.Time
+	// GameMinutes is the playing time, BreakMinutes the changeover before the
+	// next slot.
+	GameMinutes  int
+	BreakMinutes int
+	// Slots is the number of time slots the hall is booked for; zero means
+	// unlimited.
+	Slots   int
+	Bracket string
+	// Advance is the number of teams per group in the knockout bracket.
+	Advance int
+}
+
+// DefaultOptions returns the options used by cmd/planner.
+func DefaultOptions() Options {
+	return Options{Groups: 2, Pitches: 2, GameMinutes: 10, BreakMinutes: 2, Bracket: BracketKnockout, Advance: 2}
+}
+
+// ParseBracket validates a bracket format; empty selects knockout.
+func ParseBracket(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", BracketKnockout:
+		return BracketKnockout, nil
+	case BracketPlacement:
+		return BracketPlacement, nil
+	}
+	return "", fmt.Errorf("bracket must be %s or %s", BracketKnockout, BracketPlacement)
+}
+
+// Validate reports options that cannot be planned for teams teams.
+func (o Options) Validate(teams int) error {
+	switch {
+	case o.Groups < 1:
+		return errors.New("at least one group is required")
+	case teams < 2*o.Groups:
+		return fmt.Errorf("%d teams are too few for %d groups of at least two", teams, o.Groups)
+	case o.Pitches < 1:
+		return errors.New("at least one pitch is required")
+	case o.GameMinutes < 1 || o.BreakMinutes < 0:
+		return errors.New("game length must be positive and breaks must not be negative")
+	case o.Slots < 0:
+		return errors.New("slots must not be negative")
+	case o.Bracket == BracketPlacement && o.Groups != 2:
+		return errors.New("the placement bracket needs exactly two groups")
+	case o.Bracket == BracketKnockout && (o.Advance < 1 || o.Advance*o.Groups < 2):
+		return errors.New("the knockout bracket needs at least two teams to advance")
+	case o.Bracket == BracketKnockout && teams < o.Advance*o.Groups:
+		return errors.New("more teams advance than take part")
+	}
+	return nil
+}
+
+// Side is one side of a match: a team, or where the team comes from until the
+// group stage or an earlier match is decided.
+type Side struct {
+	TeamID string `json:"teamId,omitempty"`
+	Team   string `json:"team,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+// Label is the team name, or the source for an undecided side.
+func (s Side) Label() string {
+	if s.Team != "" {
+		return s.Team
+	}
+	return s.Source
+}
+
+// Match is one scheduled game. The goals are left empty for the results.
+type Match struct {
+	ID      string    `json:"id"`
+	Stage   string    `json:"stage"`
+	Group   string    `json:"group,omitempty"`
+	Round   string    `json:"round"`
+	Slot    int       `json:"slot"`
+	Pitch   int       `json:"pitch"`
+	Kickoff time.Time `json:"kickoff"`
+	Home    Side      `json:"home"`
+	Away    Side      `json:"away"`
+	// BackToBack is set when a team had to play in two slots in a row.
+	BackToBack bool `json:"backToBack,omitempty"`
+	HomeGoals  *int `json:"homeGoals"`
+	AwayGoals  *int `json:"awayGoals"`
+}
+
+// Plan is a tournament: the seeded groups and every match in kickoff order.
+type Plan struct {
+	Options   Options                  `json:"options"`
+	Groups    []recommendation.Group   `json:"groups"`
+	Objective recommendation.Objective `json:"objective"`
+	Matches   []Match                  `json:"matches"`
+	Slots     int                      `json:"slots"`
+	End       time.Time                `json:"end"`
+	Warnings  []string                 `json:"warnings"`
+}
+
+// GroupName returns the letter of group index i, counted from zero.
+func GroupName(i int) string {
+	return string(rune('A' + i))
+}
+
+// New plans a tournament for sortedTeams, strongest first. The groups are
+// seeded with the balanced recommendation, so they are of even strength and
+// keep teams of one club apart where possible. Group games are spread over the
+// pitches slot by slot, round by round, and a team never plays in two slots in
+// a row unless no other game is left. The bracket follows in its own slots.
+func New(sortedTeams []model.TeamPower, opts Options) (Plan, error) {
+	if err := opts.Validate(len(sortedTeams)); err != nil {
+		return Plan{}, err
+	}
+	groups, objective := recommendation.BalancedGroups(sortedTeams, opts.Groups, recommendation.DefaultBalancedOptions())
+	for g := range groups {
+		groups[g].GroupName = "Group " + GroupName(g)
+	}
+	plan := Plan{Options: opts, Groups: groups, Objective: objective, Matches: []Match{}, Warnings: []string{}}
+
+	pending := groupGames(groups)
+	slot := 0
+	var last map[string]bool
+	for len(pending) > 0 {
+		busy := make(map[string]bool)
+		var picked []int
+		for i, m := range pending {
+			if len(picked) == opts.Pitches {
+				break
+			}
+			if busy[m.Home.TeamID] || busy[m.Away.TeamID] || last[m.Home.TeamID] || last[m.Away.TeamID] {
+				continue
+			}
+			busy[m.Home.TeamID], busy[m.Away.TeamID] = true, true
+			picked = append(picked, i)
+		}
+		if len(picked) == 0 {
+			// Only back-to-back games are left: play the next one.
+			m := pending[0]
+			busy[m.Home.TeamID], busy[m.Away.TeamID] = true, true
+			pending[0].BackToBack = true
+			picked = append(picked, 0)
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s - %s is played back to back in slot %d", m.Home.Team, m.Away.Team, slot+1))
+		}
+		for pitch, i := range picked {
+			m := pending[i]
+			m.Slot, m.Pitch = slot+1, pitch+1
+			plan.Matches = append(plan.Matches, m)
+		}
+		for k := len(picked) - 1; k >= 0; k-- {
+			pending = append(pending[:picked[k]], pending[picked[k]+1:]...)
+		}
+		last = busy
+		slot++
+	}
+
+	var rounds [][]Match
+	if opts.Bracket == BracketPlacement {
+		rounds = placementRounds(groups)
+	} else {
+		rounds = knockoutRounds(opts.Groups, opts.Advance)
+	}
+	for _, round := range rounds {
+		for start := 0; start < len(round); start += opts.Pitches {
+			for pitch, m := range round[start:min(start+opts.Pitches, len(round))] {
+				m.Slot, m.Pitch = slot+1, pitch+1
+				plan.Matches = append(plan.Matches, m)
+			}
+			slot++
+		}
+	}
+
+	plan.Slots = slot
+	if opts.Slots > 0 && plan.Slots > opts.Slots {
+		return Plan{}, fmt.Errorf("the plan needs %d slots, only %d are available: add pitches, groups or time", plan.Slots, opts.Slots)
+	}
+	step := time.Duration(opts.GameMinutes+opts.BreakMinutes) * time.Minute
+	for i := range plan.Matches {
+		plan.Matches[i].Kickoff = opts.Start.Add(time.Duration(plan.Matches[i].Slot-1) * step)
+	}
+	plan.End = opts.Start.Add(time.Duration(plan.Slots-1)*step + time.Duration(opts.GameMinutes)*time.Minute)
+	return plan, nil
+}
+
+// groupGames returns the round-robin games of all groups, round by round and
+// group by group within a round.
+func groupGames(groups []recommendation.Group) []Match {
+	type game struct {
+		round, group int
+		match        Match
+	}
+	var games []game
+	for g, group := range groups {
+		n := 0
+		for _, p := range schedule.RoundRobin(len(group.Teams), false) {
+			home, away := group.Teams[p.Home].Team, group.Teams[p.Away].Team
+			n++
+			games = append(games, game{round: p.Round, group: g, match: Match{
+				ID:    fmt.Sprintf("%s%d", GroupName(g), n),
+				Stage: StageGroup,
+				Group: GroupName(g),
+				Round: fmt.Sprintf("Group %s, round %d", GroupName(g), p.Round+1),
+				Home:  Side{TeamID: home.TeamID, Team: home.TeamName},
+				Away:  Side{TeamID: away.TeamID, Team: away.TeamName},
+			}})
+		}
+	}
+	sort.SliceStable(games, func(i, j int) bool {
+		if games[i].round != games[j].round {
+			return games[i].round < games[j].round
+		}
+		return games[i].group < games[j].group
+	})
+	out := make([]Match, len(games))
+	for i, g := range games {
+		out[i] = g.match
+	}
+	return out
+}
+
+// knockoutRounds builds the bracket for the best advance teams of every
+// group. Group winners are seeded first, then the runners-up and so on, and
+// seeds meet as in a tennis draw; where group mates would meet in the first
+// round, the lower-ranked of them trades places with a team of the same rank.
+// Seeds without an opponent get a bye. The last round holds the match for
+// third place and the final.
+func knockoutRounds(groups, advance int) [][]Match {
+	type seed struct{ rank, group int }
+	var seeds []seed
+	for rank := 1; rank <= advance; rank++ {
+		for g := 0; g < groups; g++ {
+			seeds = append(seeds, seed{rank: rank, group: g})
+		}
+	}
+	size := 1
+	for size < len(seeds) {
+		size *= 2
+	}
+	slots := make([]int, size)
+	for i, s := range bracketOrder(size) {
+		slots[i] = -1
+		if s < len(seeds) {
+			slots[i] = s
+		}
+	}
+	clash := func(i int) bool {
+		a, b := slots[i-i%2], slots[i-i%2+1]
+		return a >= 0 && b >= 0 && seeds[a].group == seeds[b].group
+	}
+	for p := 0; p < size; p += 2 {
+		if !clash(p) {
+			continue
+		}
+		// Seeds are ordered by rank, so the larger one is the lower-ranked
+		// team; it moves and the higher seed keeps its place.
+		i := p
+		if slots[p+1] > slots[p] {
+			i = p + 1
+		}
+		for j := range slots {
+			if j/2 == i/2 || slots[j] < 0 || seeds[slots[j]].rank != seeds[slots[i]].rank {
+				continue
+			}
+			slots[i], slots[j] = slots[j], slots[i]
+			if !clash(i) && !clash(j) {
+				break
+			}
+			slots[i], slots[j] = slots[j], slots[i]
+		}
+	}
+
+	// entrants are the sides of the current round; "" marks a bye.
+	entrants := make([]string, size)
+	for i, s := range slots {
+		if s >= 0 {
+			entrants[i] = fmt.Sprintf("%s Group %s", ordinal(seeds[s].rank), GroupName(seeds[s].group))
+		}
+	}
+	var rounds [][]Match
+	var semis []string
+	for len(entrants) > 1 {
+		name := roundName(len(entrants))
+		var round []Match
+		var next []string
+		for i := 0; i < len(entrants); i += 2 {
+			a, b := entrants[i], entrants[i+1]
+			switch {
+			case a == "" || b == "":
+				next = append(next, a+b)
+				continue
+			case len(entrants) == 2:
+				if len(semis) == 2 {
+					rounds = append(rounds, []Match{knockoutMatch("P3", "Third place", "Loser "+semis[0], "Loser "+semis[1])})
+				}
+				round = append(round, knockoutMatch("F", name, a, b))
+				next = append(next, "")
+				continue
+			}
+			id := fmt.Sprintf("%s%d", roundID(len(entrants)), len(round)+1)
+			round = append(round, knockoutMatch(id, fmt.Sprintf("%s %d", name, len(round)+1), a, b))
+			next = append(next, "Winner "+id)
+		}
+		if len(entrants) == 4 {
+			semis = semis[:0]
+			for _, m := range round {
+				semis = append(semis, m.ID)
+			}
+		}
+		if len(round) > 0 {
+			rounds = append(rounds, round)
+		}
+		entrants = next
+	}
+	return rounds
+}
+
+func knockoutMatch(id, round, home, away string) Match {
+	return Match{ID: id, Stage: StageKnockout, Round: round, Home: Side{Source: home}, Away: Side{Source: away}}
+}
+
+// placementRounds lets the teams of the same rank in groups A and B play for
+// places 2r-1 and 2r, lowest places first so the final ends the day. A team
+// of the larger group without a counterpart takes the last place.
+func placementRounds(groups []recommendation.Group) [][]Match {
+	ranks := min(len(groups[0].Teams), len(groups[1].Teams))
+	var rounds [][]Match
+	for rank := ranks; rank >= 1; rank-- {
+		place := 2*rank - 1
+		round := "Final"
+		if place > 1 {
+			round = fmt.Sprintf("Places %d-%d", place, place+1)
+		}
+		rounds = append(rounds, []Match{{
+			ID:    fmt.Sprintf("P%d", place),
+			Stage: StagePlacement,
+			Round: round,
+			Home:  Side{Source: fmt.Sprintf("%s Group A", ordinal(rank))},
+			Away:  Side{Source: fmt.Sprintf("%s Group B", ordinal(rank))},
+		}})
+	}
+	return rounds
+}
+
+// bracketOrder returns the seed (from zero) of every bracket position, so
+// that seed 1 and 2 can only meet in the final.
+func bracketOrder(size int) []int {
+	order := []int{0}
+	for len(order) < size {
+		n := len(order) * 2
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n-1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+func roundName(entrants int) string {
+	switch entrants {
+	case 2:
+		return "Final"
+	case 4:
+		return "Semi-final"
+	case 8:
+		return "Quarter-final"
+	}
+	return fmt.Sprintf("Round of %d", entrants)
+}
+
+func roundID(entrants int) string {
+	switch entrants {
+	case 4:
+		return "SF"
+	case 8:
+		return "QF"
+	}
+	return fmt.Sprintf("R%d-", entrants)
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}